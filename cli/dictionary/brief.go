@@ -1,11 +1,17 @@
 package dictionary
 
-import "strings"
-
+// Brief is a sequence of one or more strokes
 type Brief struct {
 	strokes []Keymask
 }
 
+// NewBrief builds a brief out of the given strokes, in order
+func NewBrief(strokes ...Keymask) *Brief {
+	copied := make([]Keymask, len(strokes))
+	copy(copied, strokes)
+	return &Brief{strokes: copied}
+}
+
 func SingleStrokeBrief(k Keymask) *Brief {
 	return &Brief{
 		strokes: []Keymask{k},
@@ -14,37 +20,83 @@ func SingleStrokeBrief(k Keymask) *Brief {
 
 const separator = "/"
 
+// String returns the string representation of the receiver, using the
+// English system. Use System.BriefString for other systems.
 func (b *Brief) String() string {
-	masks := make([]string, len(b.strokes))
+	return English.BriefString(b)
+}
+
+// Strokes returns a copy of the strokes that make up the receiver
+func (b *Brief) Strokes() []Keymask {
+	strokes := make([]Keymask, len(b.strokes))
+	copy(strokes, b.strokes)
+	return strokes
+}
+
+// Len returns the number of strokes in the receiver
+func (b *Brief) Len() int {
+	return len(b.strokes)
+}
+
+// key returns the normalized form of the receiver, suitable for use as a map
+// key. Two briefs with the same strokes always have the same key, whatever
+// system they belong to.
+func (b *Brief) key() string {
+	buf := make([]byte, 4*len(b.strokes))
+	for i, stroke := range b.strokes {
+		buf[4*i] = byte(stroke >> 24)
+		buf[4*i+1] = byte(stroke >> 16)
+		buf[4*i+2] = byte(stroke >> 8)
+		buf[4*i+3] = byte(stroke)
+	}
+	return string(buf)
+}
+
+// Less reports whether the receiver sorts before the other brief in steno
+// order, comparing stroke by stroke.
+func (b *Brief) Less(other *Brief) bool {
 	for i, stroke := range b.strokes {
-		masks[i] = stroke.String()
+		if i >= len(other.strokes) {
+			return false
+		}
+		if stroke != other.strokes[i] {
+			return strokeLess(stroke, other.strokes[i])
+		}
 	}
-	return strings.Join(masks, separator)
+	return len(b.strokes) < len(other.strokes)
+}
+
+// strokeLess reports whether stroke a sorts before stroke b in steno order,
+// i.e. comparing their keys one by one from the start of the steno order.
+func strokeLess(a, b Keymask) bool {
+	diff := a ^ b
+	// the highest differing bit is the first key that only one stroke has
+	highest := diff
+	for highest&(highest-1) != 0 {
+		highest &= highest - 1
+	}
+	// the stroke with that key sorts first, unless the other stroke has
+	// nothing left after it
+	lower := highest - 1
+	if a&highest != 0 {
+		return b&lower != 0
+	}
+	return a&lower == 0
 }
 
 func (b *Brief) isEqual(other *Brief) bool {
 	if len(b.strokes) != len(other.strokes) {
 		return false
 	}
-	for _, stroke := range b.strokes {
-		for _, otherStroke := range other.strokes {
-			if stroke != otherStroke {
-				return false
-			}
+	for i, stroke := range b.strokes {
+		if stroke != other.strokes[i] {
+			return false
 		}
 	}
 	return true
 }
 
+// ParseBrief parses a brief of one or more strokes, using the English system
 func ParseBrief(in string) (*Brief, error) {
-	strokes := strings.Split(in, separator)
-	masks := make([]Keymask, len(strokes))
-	for i, stroke := range strokes {
-		mask, err := ParseStroke(stroke)
-		if err != nil {
-			return nil, err
-		}
-		masks[i] = mask
-	}
-	return &Brief{masks}, nil
+	return English.ParseBrief(in)
 }
@@ -0,0 +1,183 @@
+package dictionary
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// CollisionPolicy decides what happens to generated entries whose strokes are
+// already defined in a base dictionary.
+type CollisionPolicy int
+
+const (
+	// KeepCollisions leaves colliding entries where they are, so that they
+	// shadow (or are shadowed by) the base dictionary
+	KeepCollisions CollisionPolicy = iota
+	// DropCollisions removes colliding entries
+	DropCollisions
+	// MoveCollisions moves each colliding entry to a free chord (see
+	// ResolveCollisions)
+	MoveCollisions
+)
+
+// Collision is a generated entry whose stroke is already defined in a base
+// dictionary.
+type Collision struct {
+	brief     *Brief
+	Stroke    string     `json:"stroke"`
+	Generated string     `json:"generated"`
+	Existing  StackEntry `json:"existing"`
+	// Rank is the rank of the existing translation in the word frequency
+	// list, or 0 if it is not in the list
+	Rank int `json:"rank,omitempty"`
+	// Score is the ergonomic score of the stroke
+	Score float64 `json:"score"`
+	// MovedTo is the stroke the generated entry was moved to, if it was moved
+	MovedTo string `json:"movedTo,omitempty"`
+	// MovedToScore is the ergonomic score of MovedTo
+	MovedToScore float64 `json:"movedToScore,omitempty"`
+	// Dropped is true if the generated entry was removed
+	Dropped bool `json:"dropped,omitempty"`
+}
+
+// FindCollisions returns every entry of the generated dictionary whose stroke
+// the base stack already defines. They are ranked by the frequency of the
+// word they collide with, most frequent first, followed by words that are not
+// ranked, in steno order. Strokes are scored with the given scorer, or with
+// DefaultScoreWeights if it is nil.
+func FindCollisions(generated *Dictionary, base *Stack, freq *Frequencies, sc *Scorer) []Collision {
+	sc = generated.scorer(sc)
+	collisions := make([]Collision, 0)
+	ranked := make([]bool, 0)
+	generated.Each(func(b *Brief, translation string) bool {
+		existing, source, ok := base.Lookup(b)
+		if !ok {
+			return true
+		}
+		rank, isRanked := freq.Rank(existing)
+		collisions = append(collisions, Collision{
+			brief:     b,
+			Stroke:    generated.system.BriefString(b),
+			Generated: translation,
+			Existing:  StackEntry{Translation: existing, Source: source},
+			Rank:      rank,
+			Score:     sc.BriefScore(b),
+		})
+		ranked = append(ranked, isRanked)
+		return true
+	})
+	// Each is in steno order, so a stable sort keeps ties in steno order
+	order := make([]int, len(collisions))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		return RankLess(collisions[a].Rank, ranked[a], collisions[b].Rank, ranked[b])
+	})
+	sorted := make([]Collision, len(collisions))
+	for i, index := range order {
+		sorted[i] = collisions[index]
+	}
+	return sorted
+}
+
+// ResolveCollisions applies the given policy to the generated dictionary, and
+// records what happened to each collision. Collisions are handled in order,
+// so when moving, the first ones get the first pick of the free chords.
+//
+// A moved entry goes to its own chord plus one more key: the star if it is
+// free, or else the first free key in steno order (leaving out the number
+// key). A chord is free if neither the base stack nor the generated
+// dictionary defines it. Entries with no free chord are dropped. Moved
+// strokes are scored with the given scorer, as in FindCollisions.
+func ResolveCollisions(generated *Dictionary, base *Stack, collisions []Collision, policy CollisionPolicy, sc *Scorer) {
+	if policy == KeepCollisions {
+		return
+	}
+	sc = generated.scorer(sc)
+	system := generated.system
+	candidates := make([]Keymask, 0)
+	if star := system.star(); star != 0 {
+		candidates = append(candidates, star)
+	}
+	for i := range system.letters {
+		if k := system.bit(i); k != system.star() && k != system.numberKey {
+			candidates = append(candidates, k)
+		}
+	}
+
+	for i := range collisions {
+		c := &collisions[i]
+		generated.Remove(c.brief)
+		if policy == MoveCollisions {
+			if alternative := freeChord(c.brief, candidates, generated, base); alternative != nil {
+				generated.Add(alternative, c.Generated)
+				c.MovedTo = system.BriefString(alternative)
+				c.MovedToScore = sc.BriefScore(alternative)
+				continue
+			}
+		}
+		c.Dropped = true
+	}
+}
+
+// scorer returns the given scorer, or if it is nil, one for the receiver's
+// system with the default weights
+func (d *Dictionary) scorer(sc *Scorer) *Scorer {
+	if sc != nil {
+		return sc
+	}
+	if d.system == English {
+		return englishScorer
+	}
+	// the default weights have no key costs, so this can't fail
+	sc, _ = NewScorer(d.system, DefaultScoreWeights)
+	return sc
+}
+
+// freeChord returns the first chord made by adding one of the candidate keys
+// to the last stroke of the given brief that neither dictionary defines, or nil
+// if there isn't one.
+func freeChord(b *Brief, candidates []Keymask, generated *Dictionary, base *Stack) *Brief {
+	strokes := b.Strokes()
+	last := strokes[len(strokes)-1]
+	for _, k := range candidates {
+		if last&k != 0 {
+			continue
+		}
+		strokes[len(strokes)-1] = last | k
+		alternative := NewBrief(strokes...)
+		if _, ok := generated.Lookup(alternative); ok {
+			continue
+		}
+		if _, _, ok := base.Lookup(alternative); ok {
+			continue
+		}
+		return alternative
+	}
+	return nil
+}
+
+// WriteCollisionsText writes the given collisions to w, one per line, with
+// their scores and what happened to each of them.
+func WriteCollisionsText(w io.Writer, collisions []Collision) error {
+	for _, c := range collisions {
+		rank := "-"
+		if c.Rank > 0 {
+			rank = fmt.Sprint(c.Rank)
+		}
+		line := fmt.Sprintf("%s\t%s\t%.1f\t%s\tshadows %s\t(%s)", rank, c.Stroke, c.Score, c.Generated, c.Existing.Translation, c.Existing.Source)
+		switch {
+		case c.MovedTo != "":
+			line += fmt.Sprintf("\tmoved to %s\t%.1f", c.MovedTo, c.MovedToScore)
+		case c.Dropped:
+			line += "\tdropped"
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package dictionary
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func newTestDictionary(t *testing.T, entries map[string]string) *Dictionary {
+	t.Helper()
+	d := NewDictionary()
+	for stroke, translation := range entries {
+		b, err := ParseBrief(stroke)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Add(b, translation)
+	}
+	return d
+}
+
+func TestFindCollisions(t *testing.T) {
+	generated := newTestDictionary(t, map[string]string{
+		"TEFT": "{#t}",
+		"KAT":  "{#c}",
+		"PHOU": "{#m}",
+		"SKP":  "{#Escape}",
+	})
+	base := NewStack()
+	base.Push("main.json", newTestDictionary(t, map[string]string{
+		"TEFT": "test",
+		"KAT":  "cat",
+		"PHOU": "mouse",
+	}))
+	base.Push("user.json", newTestDictionary(t, map[string]string{
+		"KAT": "Cat",
+	}))
+	freq := NewFrequencies([]string{"the", "cat", "test"})
+
+	collisions := FindCollisions(generated, base, freq, nil)
+	strokes := make([]string, len(collisions))
+	for i, c := range collisions {
+		strokes[i] = c.Stroke
+	}
+	if expected := []string{"KAT", "TEFT", "PHOU"}; !reflect.DeepEqual(strokes, expected) {
+		t.Fatalf("expected collisions %v, got %v", expected, strokes)
+	}
+	if c := collisions[0]; c.Rank != 2 || c.Existing != (StackEntry{"Cat", "user.json"}) || c.Generated != "{#c}" {
+		t.Errorf("expected KAT to collide with the top of the stack, got %+v", c)
+	}
+	if collisions[2].Rank != 0 {
+		t.Errorf("expected mouse not to be ranked, got %d", collisions[2].Rank)
+	}
+}
+
+func TestResolveCollisions(t *testing.T) {
+	entries := map[string]string{
+		"TEFT": "{#t}",
+		"KAT":  "{#c}",
+		"SKP":  "{#Escape}",
+	}
+	base := NewStack()
+	base.Push("main.json", newTestDictionary(t, map[string]string{
+		"TEFT":  "test",
+		"T*EFT": "Test",
+		"KAT":   "cat",
+	}))
+
+	generated := newTestDictionary(t, entries)
+	collisions := FindCollisions(generated, base, nil, nil)
+	ResolveCollisions(generated, base, collisions, KeepCollisions, nil)
+	if generated.Len() != 3 || collisions[0].Dropped || collisions[0].MovedTo != "" {
+		t.Errorf("expected keep to leave the dictionary alone")
+	}
+
+	generated = newTestDictionary(t, entries)
+	collisions = FindCollisions(generated, base, nil, nil)
+	ResolveCollisions(generated, base, collisions, DropCollisions, nil)
+	if generated.Len() != 1 || !collisions[0].Dropped || !collisions[1].Dropped {
+		t.Errorf("expected drop to remove both collisions, got %d entries", generated.Len())
+	}
+
+	generated = newTestDictionary(t, entries)
+	collisions = FindCollisions(generated, base, nil, nil)
+	ResolveCollisions(generated, base, collisions, MoveCollisions, nil)
+	moves := map[string]string{}
+	for _, c := range collisions {
+		moves[c.Stroke] = c.MovedTo
+	}
+	// T*EFT is taken, so TEFT goes to the first free key in steno order
+	if expected := map[string]string{"KAT": "KA*T", "TEFT": "STEFT"}; !reflect.DeepEqual(moves, expected) {
+		t.Errorf("expected moves %v, got %v", expected, moves)
+	}
+	for stroke, translation := range map[string]string{"KA*T": "{#c}", "STEFT": "{#t}", "KAT": "", "SKP": "{#Escape}"} {
+		b, _ := ParseBrief(stroke)
+		if actual, _ := generated.Lookup(b); actual != translation {
+			t.Errorf("expected %s to be %q, got %q", stroke, translation, actual)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteCollisionsText(buf, collisions); err != nil {
+		t.Fatal(err)
+	}
+	// unranked collisions are in steno order
+	expected := "-\tTEFT\t5.0\t{#t}\tshadows test\t(main.json)\tmoved to STEFT\t6.0\n-\tKAT\t4.0\t{#c}\tshadows cat\t(main.json)\tmoved to KA*T\t5.0\n"
+	if buf.String() != expected {
+		t.Errorf("expected report\n%s\ngot\n%s", expected, buf.String())
+	}
+}
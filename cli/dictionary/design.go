@@ -0,0 +1,415 @@
+package dictionary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/bits"
+	"sort"
+)
+
+// Design describes a rules file for Search to complete: the bindings it must
+// have, which keys each kind of chord may use, and any strokes that are
+// already pinned. Design files are version 2 rules files in which strokes
+// may be left blank, with three more fields:
+//
+//	"modifierKeys": the keys the layer and modifiers may use, e.g. "-FRPBLGTSDZ"
+//	"keyKeys":      the keys the key chords may use, e.g. "STKPWHRAO*EU"
+//	"maxKeys":      the most keys a designed key chord may have (default 4)
+type Design struct {
+	// Rules holds the bindings to design. Blank masks (the layer, a
+	// modifier or a key) are chosen by Search; the others are pinned.
+	Rules *Rules
+	// ModifierKeys are the keys the layer and designed modifiers may use
+	ModifierKeys Keymask
+	// KeyKeys are the keys designed key chords may use
+	KeyKeys Keymask
+	// MaxKeys is the most keys a designed key chord may have
+	MaxKeys int
+
+	// system is the steno system the masks belong to. nil means English.
+	system *System
+}
+
+// defaultDesignMaxKeys is the MaxKeys of design files that don't set it
+const defaultDesignMaxKeys = 4
+
+// maxModifierKeys is the most keys a designed modifier adds to the layer
+const maxModifierKeys = 2
+
+// DefaultDesignBudget is the number of chords Search tries before giving up,
+// unless told otherwise
+const DefaultDesignBudget = 1000000
+
+// designOversample is how many more designs Search finds than it returns, so
+// that it can pick the best of them
+const designOversample = 4
+
+func (d *Design) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	var modifierKeys, keyKeys string
+	maxKeys := defaultDesignMaxKeys
+	fields := map[string]interface{}{
+		"modifierKeys": &modifierKeys,
+		"keyKeys":      &keyKeys,
+		"maxKeys":      &maxKeys,
+	}
+	for name, value := range fields {
+		if rawValue, ok := raw[name]; ok {
+			if err := json.Unmarshal(rawValue, value); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			delete(raw, name)
+		}
+	}
+	if version, ok := raw["version"]; ok && string(version) != "2" {
+		return fmt.Errorf("design files must be version 2 rules files, not version %s", version)
+	}
+	raw["version"] = json.RawMessage("2")
+	rulesBytes, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	rules := Rules{system: d.system}
+	if err := rules.unmarshalV2(rulesBytes); err != nil {
+		return err
+	}
+
+	newDesign := Design{Rules: &rules, MaxKeys: maxKeys, system: d.system}
+	if newDesign.ModifierKeys, err = newDesign.sys().ParseStroke(modifierKeys); err != nil {
+		return fmt.Errorf("modifierKeys: %v", err)
+	}
+	if newDesign.KeyKeys, err = newDesign.sys().ParseStroke(keyKeys); err != nil {
+		return fmt.Errorf("keyKeys: %v", err)
+	}
+	if maxKeys < 1 {
+		return fmt.Errorf("maxKeys must be at least 1")
+	}
+	*d = newDesign
+	return nil
+}
+
+// sys returns the steno system the receiver's masks belong to
+func (d *Design) sys() *System {
+	if d.system == nil {
+		return English
+	}
+	return d.system
+}
+
+// ReadDesignFile reads a design file whose strokes are written for the
+// English system
+func ReadDesignFile(filename string) (*Design, error) {
+	return ReadSystemDesignFile(filename, English)
+}
+
+// ReadSystemDesignFile reads a design file whose strokes are written for the
+// given system
+func ReadSystemDesignFile(filename string, s *System) (*Design, error) {
+	inBytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	d := Design{system: s}
+	if err = json.Unmarshal(inBytes, &d); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return &d, nil
+}
+
+// DesignOpts tune a Search
+type DesignOpts struct {
+	// Against is a stack of dictionaries whose single strokes the generated
+	// strokes must avoid. It may be nil.
+	Against *Stack
+	// Score rates how hard a stroke is to write; lower is easier. If it is
+	// nil, strokes are scored with DefaultScoreWeights.
+	Score func(Keymask) float64
+	// Candidates is the most designs to return. If it is 0, one is returned.
+	Candidates int
+	// Budget is the most chords to try. If it is 0, DefaultDesignBudget is
+	// used.
+	Budget int
+}
+
+// DesignCandidate is a complete set of rules found by Search
+type DesignCandidate struct {
+	Rules *Rules
+	// Score is the mean score of every stroke the rules generate
+	Score float64
+}
+
+// Search looks for rules that complete the receiver and pass MustBeValid. It
+// is a backtracking search: the layer, then each modifier, then each key is
+// given the easiest chord that doesn't collide with anything chosen so far
+// (or with the dictionaries to avoid), and when a binding has no chord left
+// the search goes back and changes the one before it. Candidates are
+// returned best first.
+func (d *Design) Search(opts DesignOpts) ([]DesignCandidate, error) {
+	if opts.Score == nil {
+		sc, err := NewScorer(d.sys(), DefaultScoreWeights)
+		if err != nil {
+			return nil, err
+		}
+		opts.Score = sc.Score
+	}
+	if opts.Candidates == 0 {
+		opts.Candidates = 1
+	}
+	if opts.Budget == 0 {
+		opts.Budget = DefaultDesignBudget
+	}
+	s := &designer{
+		design: d,
+		opts:   opts,
+		system: d.sys(),
+		budget: opts.Budget,
+		limit:  opts.Candidates * designOversample,
+		base:   make(map[Keymask]bool),
+		used:   make(map[Keymask]bool),
+	}
+	if opts.Against != nil {
+		opts.Against.Flatten().Each(func(b *Brief, _ string) bool {
+			if b.Len() == 1 {
+				s.base[b.strokes[0]] = true
+			}
+			return true
+		})
+	}
+	s.rules = *d.Rules
+	s.rules.Version = RulesV2
+	s.rules.Modifiers = append([]ModifierBinding{}, d.Rules.Modifiers...)
+	s.rules.Keys = append([]KeyBinding{}, d.Rules.Keys...)
+	s.keyCandidates = s.sorted(subsets(d.KeyKeys, d.MaxKeys))
+
+	s.searchLayer()
+	if len(s.found) == 0 {
+		if s.budget <= 0 {
+			return nil, fmt.Errorf("no valid rules were found within the budget of %d chords", opts.Budget)
+		}
+		return nil, fmt.Errorf("no valid rules satisfy the design")
+	}
+	sort.SliceStable(s.found, func(i, j int) bool {
+		return s.found[i].Score < s.found[j].Score
+	})
+	if len(s.found) > opts.Candidates {
+		s.found = s.found[:opts.Candidates]
+	}
+	return s.found, nil
+}
+
+// designer holds the state of a Search
+type designer struct {
+	design *Design
+	opts   DesignOpts
+	system *System
+	// rules is the design so far
+	rules Rules
+	// budget is the number of chords left to try
+	budget int
+	// limit is the number of designs to find before stopping
+	limit int
+	found []DesignCandidate
+	// base holds the single strokes of the dictionaries to avoid
+	base map[Keymask]bool
+	// used holds every stroke the rules generate so far
+	used map[Keymask]bool
+	// modChords are the modifier chords, once they have been chosen
+	modChords     []modifierChord
+	keyCandidates []Keymask
+}
+
+func (s *designer) done() bool {
+	return len(s.found) >= s.limit || s.budget <= 0
+}
+
+// sorted orders the given chords from easiest to hardest
+func (s *designer) sorted(chords []Keymask) []Keymask {
+	scores := make(map[Keymask]float64, len(chords))
+	for _, k := range chords {
+		scores[k] = s.opts.Score(k)
+	}
+	sort.SliceStable(chords, func(i, j int) bool {
+		if scores[chords[i]] != scores[chords[j]] {
+			return scores[chords[i]] < scores[chords[j]]
+		}
+		return strokeLess(chords[i], chords[j])
+	})
+	return chords
+}
+
+// subsets returns every non-empty combination of the given keys with at most
+// max keys
+func subsets(keys Keymask, max int) []Keymask {
+	all := make([]Keymask, 0)
+	for sub := keys; sub > 0; sub = (sub - 1) & keys {
+		if bits.OnesCount32(uint32(sub)) <= max {
+			all = append(all, sub)
+		}
+	}
+	return all
+}
+
+func (s *designer) searchLayer() {
+	if s.rules.Layer != 0 {
+		s.searchModifiers(0)
+		return
+	}
+	for _, layer := range s.sorted(subsets(s.design.ModifierKeys, s.design.MaxKeys)) {
+		s.rules.Layer = layer
+		s.searchModifiers(0)
+		if s.done() {
+			break
+		}
+	}
+	s.rules.Layer = 0
+}
+
+// searchModifiers designs the modifiers from the i'th on. Each designed
+// modifier is the layer plus keys no other modifier uses.
+func (s *designer) searchModifiers(i int) {
+	if i == len(s.rules.Modifiers) {
+		s.placeModifiers()
+		return
+	}
+	if s.design.Rules.Modifiers[i].Mask != 0 {
+		s.searchModifiers(i + 1)
+		return
+	}
+	taken := s.rules.Layer
+	for j, m := range s.rules.Modifiers {
+		if j != i {
+			taken |= m.Mask &^ s.rules.Layer
+		}
+	}
+	for _, extra := range s.sorted(subsets(s.design.ModifierKeys&^taken, maxModifierKeys)) {
+		s.budget--
+		s.rules.Modifiers[i].Mask = s.rules.Layer | extra
+		s.searchModifiers(i + 1)
+		if s.done() {
+			break
+		}
+	}
+	s.rules.Modifiers[i].Mask = 0
+}
+
+// placeModifiers checks the chosen modifier chords, then places the pinned key
+// chords and searches for the rest
+func (s *designer) placeModifiers() {
+	s.modChords = s.rules.modifierChords()
+	placed := make([]Keymask, 0)
+	defer func() {
+		for _, k := range placed {
+			delete(s.used, k)
+		}
+	}()
+	for _, m := range s.modChords {
+		if m.mask == 0 || s.used[m.mask] || s.system.IsFingerspelling(m.mask) {
+			return
+		}
+		s.used[m.mask] = true
+		placed = append(placed, m.mask)
+	}
+	pinned := append(s.rules.symbolChords(s.rules.Options), numberChords(s.system, s.rules.Options)...)
+	for _, k := range s.rules.Keys {
+		if k.Mask != 0 {
+			pinned = append(pinned, keyChord{k.Name, k.Mask, k.Keysym})
+		}
+	}
+	for _, chord := range pinned {
+		strokes, ok := s.place(chord.mask)
+		if !ok {
+			return
+		}
+		placed = append(placed, strokes...)
+	}
+	s.searchKeys(0)
+}
+
+// place adds the given key chord, and its combination with every modifier
+// chord, to the strokes in use. It returns the strokes it added, or false if
+// any of them was already in use, matches a fingerspelling, or is in the
+// dictionaries to avoid.
+func (s *designer) place(k Keymask) ([]Keymask, bool) {
+	strokes := make([]Keymask, 0, len(s.modChords)+1)
+	add := func(stroke Keymask, generated bool) bool {
+		if stroke == 0 || s.used[stroke] || s.system.IsFingerspelling(stroke) {
+			return false
+		}
+		if generated && s.base[stroke] {
+			return false
+		}
+		s.used[stroke] = true
+		strokes = append(strokes, stroke)
+		return true
+	}
+	ok := add(k, false)
+	for _, m := range s.modChords {
+		if !ok {
+			break
+		}
+		generated := !m.nonstandard || s.rules.Options.NonstandardModCombinations
+		ok = add(m.mask|k, generated)
+	}
+	if !ok {
+		for _, stroke := range strokes {
+			delete(s.used, stroke)
+		}
+		return nil, false
+	}
+	return strokes, true
+}
+
+// searchKeys designs the keys from the i'th on
+func (s *designer) searchKeys(i int) {
+	if i == len(s.rules.Keys) {
+		s.record()
+		return
+	}
+	if s.design.Rules.Keys[i].Mask != 0 {
+		s.searchKeys(i + 1)
+		return
+	}
+	for _, k := range s.keyCandidates {
+		s.budget--
+		strokes, ok := s.place(k)
+		if ok {
+			s.rules.Keys[i].Mask = k
+			s.searchKeys(i + 1)
+			for _, stroke := range strokes {
+				delete(s.used, stroke)
+			}
+		}
+		if s.done() {
+			break
+		}
+	}
+	s.rules.Keys[i].Mask = 0
+}
+
+// record keeps a copy of the current design if it passes MustBeValid
+func (s *designer) record() {
+	rules := s.rules
+	rules.Modifiers = append([]ModifierBinding{}, s.rules.Modifiers...)
+	rules.Keys = append([]KeyBinding{}, s.rules.Keys...)
+	if errs := rules.MustBeValid(); len(errs) > 0 {
+		return
+	}
+	total := 0.0
+	d, err := NewFactory(rules.Options).Generate(&rules)
+	if err != nil {
+		return
+	}
+	d.Each(func(b *Brief, _ string) bool {
+		total += s.opts.Score(b.strokes[0])
+		return true
+	})
+	candidate := DesignCandidate{Rules: &rules}
+	if d.Len() > 0 {
+		candidate.Score = total / float64(d.Len())
+	}
+	s.found = append(s.found, candidate)
+}
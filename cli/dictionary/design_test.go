@@ -0,0 +1,117 @@
+package dictionary
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDesignSearch(t *testing.T) {
+	d, err := ReadDesignFile("../../dictionaries/design-rules.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidates, err := d.Search(DesignOpts{Candidates: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("expected 2 candidates, got %d", len(candidates))
+	}
+	if candidates[0].Score > candidates[1].Score {
+		t.Errorf("expected the best candidate first, got %v then %v", candidates[0].Score, candidates[1].Score)
+	}
+	for _, c := range candidates {
+		if errs := c.Rules.MustBeValid(); len(errs) > 0 {
+			t.Errorf("expected the candidate to be valid, got %v", errs)
+		}
+		if c.Rules.Layer != RightF|RightR|RightL|RightG || c.Rules.Keys[0].Mask != LeftS|LeftK|LeftP {
+			t.Errorf("expected the pinned layer and escape to be kept")
+		}
+		for _, m := range c.Rules.Modifiers {
+			if m.Mask&c.Rules.Layer != c.Rules.Layer || m.Mask&^(RightF|RightR|RightP|RightB|RightL|RightG|RightT|RightS|RightD|RightZ) != 0 {
+				t.Errorf("expected %s to be the layer plus modifier keys, got %s", m.Name, m.Mask)
+			}
+		}
+		for _, k := range c.Rules.Keys {
+			if k.Mask&^(LeftS|LeftT|LeftK|LeftP|LeftW|LeftH|LeftR|LeftA|LeftO|Star|RightE|RightU) != 0 {
+				t.Errorf("expected %s to use key keys only, got %s", k.Name, k.Mask)
+			}
+		}
+	}
+	// the design itself is left alone
+	if d.Rules.Keys[1].Mask != 0 {
+		t.Errorf("expected the design not to change")
+	}
+
+	// * on the layer is Space in the best design, so avoiding it moves Space
+	space := candidates[0].Rules.Keys[1]
+	avoid := NewDictionary()
+	avoid.Add(SingleStrokeBrief(space.Mask|d.Rules.Layer), "avoided")
+	base := NewStack()
+	base.Push("main.json", avoid)
+	avoiding, err := d.Search(DesignOpts{Against: base})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if avoiding[0].Rules.Keys[1].Mask == space.Mask {
+		t.Errorf("expected %s not to be used for space", space.Mask)
+	}
+}
+
+func TestDesignSearchFails(t *testing.T) {
+	d := Design{}
+	err := json.Unmarshal([]byte(`{
+		"modifierKeys": "-FRPBLG",
+		"keyKeys": "ST",
+		"maxKeys": 1,
+		"layer": "-FRLG",
+		"modifiers": [{"keysym": "Shift_L"}],
+		"keys": [{"keysym": "Up"}, {"keysym": "Down"}, {"keysym": "Left"}]
+	}`), &d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Search(DesignOpts{}); err == nil || err.Error() != "no valid rules satisfy the design" {
+		t.Errorf("expected three keys not to fit on two chords, got %v", err)
+	}
+	if _, err := d.Search(DesignOpts{Budget: 1}); err == nil || err.Error() != "no valid rules were found within the budget of 1 chords" {
+		t.Errorf("expected the search to run out of budget, got %v", err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"version": 1, "keyKeys": "ST"}`), &d); err == nil {
+		t.Errorf("expected a version 1 design to be an error")
+	}
+	if err := json.Unmarshal([]byte(`{"keyKeys": "SQ"}`), &d); err == nil {
+		t.Errorf("expected keyKeys that are not keys to be an error")
+	}
+}
+
+func TestRulesMarshalJSON(t *testing.T) {
+	for _, filename := range []string{"../../dictionaries/generator-rules.json", "../../dictionaries/generator-rules-v2.json"} {
+		r, err := ReadRulesFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := r.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		written := Rules{system: English}
+		if err := json.Unmarshal(b, &written); err != nil {
+			t.Fatal(err)
+		}
+		if r.Version == RulesV1 {
+			written.Options = DefaultFactoryOpts
+			r.Options = DefaultFactoryOpts
+		}
+		expected := mustGenerate(t, r.Options, r)
+		actual := mustGenerate(t, written.Options, &written)
+		if diff := NewDiff(expected, actual); len(diff.OnlyInA)+len(diff.OnlyInB)+len(diff.Conflicts) > 0 {
+			t.Errorf("expected %s to generate the same dictionary once written, got %+v", filename, diff)
+		}
+		if r.Version == RulesV2 && !reflect.DeepEqual(r, &written) {
+			t.Errorf("expected %s to round trip, got %+v", filename, written)
+		}
+	}
+}
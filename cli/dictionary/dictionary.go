@@ -5,25 +5,158 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/apex/log"
 )
 
-// Dictionary represents a mapping of briefs to qwertystrokes
-type Dictionary map[*Brief]string
+// Dictionary represents a mapping of briefs to translations. Entries are keyed
+// by their normalized stroke sequence, so two equal briefs always refer to the
+// same entry. The receiver also keeps a reverse index from each translation to
+// every brief that produces it.
+type Dictionary struct {
+	system  *System
+	entries map[string]*entry
+	reverse map[string][]*Brief
+	// longest is the number of strokes in the longest brief ever added
+	longest int
+}
+
+type entry struct {
+	brief       *Brief
+	translation string
+}
+
+// NewDictionary returns an empty dictionary for the English system
+func NewDictionary() *Dictionary {
+	return NewSystemDictionary(English)
+}
+
+// NewSystemDictionary returns an empty dictionary whose strokes belong to the
+// given system
+func NewSystemDictionary(s *System) *Dictionary {
+	return &Dictionary{
+		system:  s,
+		entries: make(map[string]*entry),
+		reverse: make(map[string][]*Brief),
+	}
+}
+
+// System returns the steno system the receiver's strokes belong to
+func (d *Dictionary) System() *System {
+	return d.system
+}
+
+// Add maps the given brief to the given translation, replacing any
+// translation the brief already had.
+func (d *Dictionary) Add(b *Brief, translation string) {
+	key := b.key()
+	if existing, ok := d.entries[key]; ok {
+		if existing.translation == translation {
+			return
+		}
+		d.removeReverse(existing)
+	}
+	e := &entry{brief: b, translation: translation}
+	d.entries[key] = e
+	d.reverse[translation] = append(d.reverse[translation], b)
+	if b.Len() > d.longest {
+		d.longest = b.Len()
+	}
+}
+
+// Remove deletes the given brief from the receiver. It returns false if the
+// brief was not present.
+func (d *Dictionary) Remove(b *Brief) bool {
+	key := b.key()
+	existing, ok := d.entries[key]
+	if !ok {
+		return false
+	}
+	d.removeReverse(existing)
+	delete(d.entries, key)
+	return true
+}
+
+func (d *Dictionary) removeReverse(e *entry) {
+	briefs := d.reverse[e.translation]
+	for i, b := range briefs {
+		if b.isEqual(e.brief) {
+			briefs = append(briefs[:i], briefs[i+1:]...)
+			break
+		}
+	}
+	if len(briefs) == 0 {
+		delete(d.reverse, e.translation)
+		return
+	}
+	d.reverse[e.translation] = briefs
+}
+
+// Lookup returns the translation for the given brief, and whether the brief
+// was present in the receiver.
+func (d *Dictionary) Lookup(b *Brief) (string, bool) {
+	e, ok := d.entries[b.key()]
+	if !ok {
+		return "", false
+	}
+	return e.translation, true
+}
+
+// ReverseLookup returns every brief that translates to the given string,
+// sorted in steno order.
+func (d *Dictionary) ReverseLookup(translation string) []*Brief {
+	briefs := make([]*Brief, len(d.reverse[translation]))
+	copy(briefs, d.reverse[translation])
+	sortBriefs(briefs)
+	return briefs
+}
 
-func (d *Dictionary) add(b *Brief, definition string) {
-	copy := map[*Brief]string(*d)
-	copy[b] = definition
-	*d = copy
+// Len returns the number of entries in the receiver
+func (d *Dictionary) Len() int {
+	return len(d.entries)
+}
+
+// LongestBrief returns the number of strokes in the longest brief that has
+// been added to the receiver.
+func (d *Dictionary) LongestBrief() int {
+	return d.longest
+}
+
+// Briefs returns every brief in the receiver, sorted in steno order.
+func (d *Dictionary) Briefs() []*Brief {
+	briefs := make([]*Brief, 0, len(d.entries))
+	for _, e := range d.entries {
+		briefs = append(briefs, e.brief)
+	}
+	sortBriefs(briefs)
+	return briefs
+}
+
+// Each calls fn for every entry in the receiver, in the order given by
+// Briefs. Iteration stops early if fn returns false.
+func (d *Dictionary) Each(fn func(b *Brief, translation string) bool) {
+	for _, b := range d.Briefs() {
+		if !fn(b, d.entries[b.key()].translation) {
+			return
+		}
+	}
+}
+
+func sortBriefs(briefs []*Brief) {
+	sort.Slice(briefs, func(i, j int) bool {
+		return briefs[i].Less(briefs[j])
+	})
 }
 
 func (d *Dictionary) MarshalJSON() ([]byte, error) {
 	// Keymasks are so large that something something stack overflow?
-	definitions := make(map[string]string)
-	copy := map[*Brief]string(*d)
-	for k, def := range copy {
-		definitions[k.String()] = def
+	definitions := make(map[string]string, len(d.entries))
+	for _, e := range d.entries {
+		definitions[d.system.BriefString(e.brief)] = e.translation
 	}
 
 	// we can't use json.Marshal because that html-escapes the > in the qwerty side
@@ -37,41 +170,89 @@ func (d *Dictionary) MarshalJSON() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func ReadFile(filename string) (*Dictionary, error) {
-	inBytes, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, err
+func (d *Dictionary) UnmarshalJSON(b []byte) error {
+	system := d.system
+	if system == nil {
+		system = English
 	}
-	var inMap map[string]string
-	if err = json.Unmarshal(inBytes, &inMap); err != nil {
-		return nil, err
+	newDict, err := ReadSystemJSON(bytes.NewReader(b), system)
+	if err != nil {
+		return err
 	}
-	briefMap := make(map[*Brief]string)
-	for key, definition := range inMap {
-		brief, err := ParseBrief(key)
+	*d = *newDict
+	return nil
+}
+
+// ReadFile reads a dictionary from the given file, using the English system.
+// Files ending in `.rtf` are read as RTF/CRE, and anything else as Plover
+// JSON.
+func ReadFile(filename string) (*Dictionary, error) {
+	return ReadSystemFile(filename, English)
+}
+
+// ReadSystemFile reads a dictionary whose strokes belong to the given system
+// from the given file.
+func ReadSystemFile(filename string, s *System) (*Dictionary, error) {
+	if isRTF(filename) {
+		inBytes, err := ioutil.ReadFile(filename)
 		if err != nil {
 			return nil, err
 		}
+		d, err := ParseSystemRTF(string(inBytes), s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		return d, nil
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, err := ReadSystemJSON(f, s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return d, nil
+}
 
-		briefMap[brief] = definition
+// WriteFile writes the receiver to the given file. Files ending in `.rtf` are
+// written as RTF/CRE, and anything else as Plover JSON.
+func (d *Dictionary) WriteFile(filename string) error {
+	var b []byte
+	if isRTF(filename) {
+		buf := new(bytes.Buffer)
+		if err := d.WriteRTF(buf); err != nil {
+			return err
+		}
+		b = buf.Bytes()
+	} else {
+		var err error
+		if b, err = d.MarshalJSON(); err != nil {
+			return err
+		}
 	}
-	d := Dictionary(briefMap)
-	return &d, nil
+	return ioutil.WriteFile(filename, b, 0644)
+}
+
+func isRTF(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == ".rtf"
 }
 
 func (d *Dictionary) MustNotCollideWith(other *Dictionary) []error {
 	errs := make([]error, 0)
-	for briefA, definitionA := range map[*Brief]string(*d) {
-		for briefB, definitionB := range map[*Brief]string(*other) {
-			if briefA.isEqual(briefB) {
-				log.WithFields(log.Fields{
-					"brief":       briefA,
-					"definitionA": definitionA,
-					"definitionB": definitionB,
-				}).Warnf("Brief collides with other dictionary")
-				errs = append(errs, fmt.Errorf("Brief %s collides with other dictionary (%s vs %s)", briefA, definitionA, definitionB))
-			}
+	d.Each(func(brief *Brief, definitionA string) bool {
+		definitionB, ok := other.Lookup(brief)
+		if !ok {
+			return true
 		}
-	}
+		log.WithFields(log.Fields{
+			"brief":       brief,
+			"definitionA": definitionA,
+			"definitionB": definitionB,
+		}).Warnf("Brief collides with other dictionary")
+		errs = append(errs, fmt.Errorf("Brief %s collides with other dictionary (%s vs %s)", d.system.BriefString(brief), definitionA, definitionB))
+		return true
+	})
 	return errs
 }
@@ -0,0 +1,87 @@
+package dictionary
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDictionaryLookup(t *testing.T) {
+	d := NewDictionary()
+	d.Add(SingleStrokeBrief(LeftT|LeftP|LeftH|RightE|RightT), "net")
+	d.Add(NewBrief(LeftT|LeftK|LeftP|LeftW|RightE|RightU|RightT, LeftH|RightU|RightB), "github")
+
+	// a different pointer to an equal brief should find the same entry
+	brief, err := ParseBrief("TKPWEUT/HUB")
+	if err != nil {
+		t.Fatal(err)
+	}
+	translation, ok := d.Lookup(brief)
+	if !ok {
+		t.Fatalf("expected %s to be found", brief)
+	}
+	if translation != "github" {
+		t.Errorf("expected translation to be github, got %s", translation)
+	}
+
+	if _, ok := d.Lookup(SingleStrokeBrief(LeftT)); ok {
+		t.Errorf("expected T to be missing")
+	}
+}
+
+func TestDictionaryReverseLookup(t *testing.T) {
+	d := NewDictionary()
+	d.Add(SingleStrokeBrief(LeftT|RightE|RightS), "test")
+	d.Add(SingleStrokeBrief(LeftT|RightE|RightS|RightT), "test")
+	d.Add(SingleStrokeBrief(LeftT|RightE|RightT), "tet")
+
+	briefs := d.ReverseLookup("test")
+	if len(briefs) != 2 {
+		t.Fatalf("expected 2 briefs for test, got %d", len(briefs))
+	}
+	// -T comes before -S in steno order
+	if briefs[0].String() != "TETS" || briefs[1].String() != "TES" {
+		t.Errorf("expected briefs TETS and TES, got %s and %s", briefs[0], briefs[1])
+	}
+
+	// replacing a translation should move the brief in the reverse index
+	d.Add(SingleStrokeBrief(LeftT|RightE|RightS), "tes")
+	if briefs := d.ReverseLookup("test"); len(briefs) != 1 {
+		t.Errorf("expected 1 brief for test after replacement, got %d", len(briefs))
+	}
+	if briefs := d.ReverseLookup("tes"); len(briefs) != 1 {
+		t.Errorf("expected 1 brief for tes after replacement, got %d", len(briefs))
+	}
+
+	d.Remove(SingleStrokeBrief(LeftT | RightE | RightT))
+	if briefs := d.ReverseLookup("tet"); len(briefs) != 0 {
+		t.Errorf("expected no briefs for tet after removal, got %d", len(briefs))
+	}
+	if d.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", d.Len())
+	}
+}
+
+func TestDictionaryJSON(t *testing.T) {
+	in := `{"TKPWEUT/HUB": "github", "R-R": "are", "R*R": "{#Return}{^}{>}"}`
+	d := NewDictionary()
+	if err := json.Unmarshal([]byte(in), d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Len() != 3 {
+		t.Fatalf("expected 3 entries, got %d", d.Len())
+	}
+
+	out, err := d.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "R*R": "{#Return}{^}{>}",
+  "R-R": "are",
+  "TKPWEUT/HUB": "github"
+}
+`
+	if string(out) != expected {
+		t.Errorf("expected %s, got %s", expected, out)
+	}
+}
@@ -0,0 +1,195 @@
+package dictionary
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DiffEntry is a single stroke from a dictionary diff, with its translation
+// in each of the two dictionaries (if present).
+type DiffEntry struct {
+	Brief string `json:"brief"`
+	A     string `json:"a,omitempty"`
+	B     string `json:"b,omitempty"`
+}
+
+// DiffTranslation is a translation that both dictionaries produce, but under
+// different strokes.
+type DiffTranslation struct {
+	Translation string   `json:"translation"`
+	A           []string `json:"a"`
+	B           []string `json:"b"`
+}
+
+// Diff represents the differences between two dictionaries, A and B.
+type Diff struct {
+	// OnlyInA holds strokes that A defines and B does not
+	OnlyInA []DiffEntry `json:"onlyInA"`
+	// OnlyInB holds strokes that B defines and A does not
+	OnlyInB []DiffEntry `json:"onlyInB"`
+	// Same holds strokes that both dictionaries translate the same way
+	Same []DiffEntry `json:"same"`
+	// Conflicts holds strokes that the dictionaries translate differently
+	Conflicts []DiffEntry `json:"conflicts"`
+	// Moved holds translations that the dictionaries produce from different
+	// strokes
+	Moved []DiffTranslation `json:"moved"`
+}
+
+// NewDiff compares the two given dictionaries and sorts every entry into the
+// groups of a Diff.
+func NewDiff(a, b *Dictionary) *Diff {
+	diff := &Diff{
+		OnlyInA:   make([]DiffEntry, 0),
+		OnlyInB:   make([]DiffEntry, 0),
+		Same:      make([]DiffEntry, 0),
+		Conflicts: make([]DiffEntry, 0),
+		Moved:     make([]DiffTranslation, 0),
+	}
+	a.Each(func(brief *Brief, translationA string) bool {
+		translationB, ok := b.Lookup(brief)
+		switch {
+		case !ok:
+			diff.OnlyInA = append(diff.OnlyInA, DiffEntry{Brief: a.system.BriefString(brief), A: translationA})
+		case translationA == translationB:
+			diff.Same = append(diff.Same, DiffEntry{Brief: a.system.BriefString(brief), A: translationA, B: translationB})
+		default:
+			diff.Conflicts = append(diff.Conflicts, DiffEntry{Brief: a.system.BriefString(brief), A: translationA, B: translationB})
+		}
+		return true
+	})
+	b.Each(func(brief *Brief, translationB string) bool {
+		if _, ok := a.Lookup(brief); !ok {
+			diff.OnlyInB = append(diff.OnlyInB, DiffEntry{Brief: b.system.BriefString(brief), B: translationB})
+		}
+		return true
+	})
+
+	translations := make([]string, 0, len(a.reverse))
+	for translation := range a.reverse {
+		if _, ok := b.reverse[translation]; ok {
+			translations = append(translations, translation)
+		}
+	}
+	sort.Strings(translations)
+	for _, translation := range translations {
+		briefsA := briefStrings(a.system, a.ReverseLookup(translation))
+		briefsB := briefStrings(b.system, b.ReverseLookup(translation))
+		if strings.Join(briefsA, " ") == strings.Join(briefsB, " ") {
+			continue
+		}
+		diff.Moved = append(diff.Moved, DiffTranslation{
+			Translation: translation,
+			A:           briefsA,
+			B:           briefsB,
+		})
+	}
+
+	return diff
+}
+
+func briefStrings(s *System, briefs []*Brief) []string {
+	strs := make([]string, len(briefs))
+	for i, b := range briefs {
+		strs[i] = s.BriefString(b)
+	}
+	return strs
+}
+
+// HasConflicts returns true if any stroke is translated differently by the
+// two dictionaries.
+func (d *Diff) HasConflicts() bool {
+	return len(d.Conflicts) > 0
+}
+
+// WriteText writes the receiver to w as plain text, one group per section.
+func (d *Diff) WriteText(w io.Writer) error {
+	sections := []struct {
+		title string
+		lines []string
+	}{
+		{"only in A", d.entryLines(d.OnlyInA, "%s\t%s", false, noEscape)},
+		{"only in B", d.entryLines(d.OnlyInB, "%s\t%s", true, noEscape)},
+		{"same", d.entryLines(d.Same, "%s\t%s", false, noEscape)},
+		{"conflicts", d.conflictLines("%s\t%s\t%s", noEscape)},
+		{"same translation, different strokes", d.movedLines("%s\tA: %s\tB: %s", noEscape)},
+	}
+	for _, section := range sections {
+		if _, err := fmt.Fprintf(w, "%s (%d):\n", section.title, len(section.lines)); err != nil {
+			return err
+		}
+		for _, line := range section.lines {
+			if _, err := fmt.Fprintf(w, "  %s\n", line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteMarkdown writes the receiver to w as a Markdown document, with a table
+// per group.
+func (d *Diff) WriteMarkdown(w io.Writer) error {
+	sections := []struct {
+		title  string
+		header string
+		lines  []string
+	}{
+		{"Only in A", "| Stroke | Translation |\n| --- | --- |", d.entryLines(d.OnlyInA, "| `%s` | `%s` |", false, markdownEscape)},
+		{"Only in B", "| Stroke | Translation |\n| --- | --- |", d.entryLines(d.OnlyInB, "| `%s` | `%s` |", true, markdownEscape)},
+		{"Same", "| Stroke | Translation |\n| --- | --- |", d.entryLines(d.Same, "| `%s` | `%s` |", false, markdownEscape)},
+		{"Conflicts", "| Stroke | A | B |\n| --- | --- | --- |", d.conflictLines("| `%s` | `%s` | `%s` |", markdownEscape)},
+		{"Same translation, different strokes", "| Translation | A | B |\n| --- | --- | --- |", d.movedLines("| `%s` | `%s` | `%s` |", markdownEscape)},
+	}
+	for _, section := range sections {
+		if _, err := fmt.Fprintf(w, "## %s (%d)\n\n", section.title, len(section.lines)); err != nil {
+			return err
+		}
+		if len(section.lines) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%s\n%s\n\n", section.header, strings.Join(section.lines, "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func noEscape(s string) string {
+	return s
+}
+
+// markdownEscape keeps a value from breaking out of its table cell
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "`", "'").Replace(s)
+}
+
+func (d *Diff) entryLines(entries []DiffEntry, format string, useB bool, escape func(string) string) []string {
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		translation := e.A
+		if useB {
+			translation = e.B
+		}
+		lines[i] = fmt.Sprintf(format, escape(e.Brief), escape(translation))
+	}
+	return lines
+}
+
+func (d *Diff) conflictLines(format string, escape func(string) string) []string {
+	lines := make([]string, len(d.Conflicts))
+	for i, e := range d.Conflicts {
+		lines[i] = fmt.Sprintf(format, escape(e.Brief), escape(e.A), escape(e.B))
+	}
+	return lines
+}
+
+func (d *Diff) movedLines(format string, escape func(string) string) []string {
+	lines := make([]string, len(d.Moved))
+	for i, m := range d.Moved {
+		lines[i] = fmt.Sprintf(format, escape(m.Translation), escape(strings.Join(m.A, ", ")), escape(strings.Join(m.B, ", ")))
+	}
+	return lines
+}
@@ -0,0 +1,62 @@
+package dictionary
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNewDiff(t *testing.T) {
+	a := NewDictionary()
+	if err := json.Unmarshal([]byte(`{
+		"TEFT": "test",
+		"TEF": "test",
+		"R-R": "are",
+		"SKP": "and",
+		"KAT": "cat"
+	}`), a); err != nil {
+		t.Fatal(err)
+	}
+	b := NewDictionary()
+	if err := json.Unmarshal([]byte(`{
+		"TEFT": "test",
+		"R-R": "{#Return}",
+		"SKP": "and",
+		"TKOG": "dog"
+	}`), b); err != nil {
+		t.Fatal(err)
+	}
+
+	diff := NewDiff(a, b)
+	cases := []struct {
+		name     string
+		entries  []DiffEntry
+		expected []string
+	}{
+		{"only in A", diff.OnlyInA, []string{"TEF", "KAT"}},
+		{"only in B", diff.OnlyInB, []string{"TKOG"}},
+		{"same", diff.Same, []string{"SKP", "TEFT"}},
+		{"conflicts", diff.Conflicts, []string{"R-R"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if len(c.entries) != len(c.expected) {
+				t.Fatalf("expected %d entries, got %d: %v", len(c.expected), len(c.entries), c.entries)
+			}
+			for i, brief := range c.expected {
+				if c.entries[i].Brief != brief {
+					t.Errorf("expected %dth entry to be %s, got %s", i, brief, c.entries[i].Brief)
+				}
+			}
+		})
+	}
+
+	if len(diff.Moved) != 1 || diff.Moved[0].Translation != "test" {
+		t.Fatalf("expected test to have moved, got %v", diff.Moved)
+	}
+	if len(diff.Moved[0].A) != 2 || len(diff.Moved[0].B) != 1 {
+		t.Errorf("expected 2 strokes in A and 1 in B, got %v", diff.Moved[0])
+	}
+	if !diff.HasConflicts() {
+		t.Errorf("expected diff to have conflicts")
+	}
+}
@@ -1,6 +1,9 @@
 package dictionary
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
 
 type NumberOption int
 
@@ -21,24 +24,129 @@ const (
 	NumberOptionFunctionsHigh NumberOption = NumberOption(iota)
 )
 
+var numberOptionNames = map[NumberOption]string{
+	NumberOptionDisabled:      "disabled",
+	NumberOptionNumbers:       "numbers",
+	NumberOptionNumbersHigh:   "numbersHigh",
+	NumberOptionFunctions:     "functions",
+	NumberOptionFunctionsHigh: "functionsHigh",
+}
+
+func (o NumberOption) String() string {
+	if name, ok := numberOptionNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("NumberOption(%d)", int(o))
+}
+
+// MarshalText writes the receiver by name, e.g. "numbersHigh"
+func (o NumberOption) MarshalText() ([]byte, error) {
+	if _, ok := numberOptionNames[o]; !ok {
+		return nil, fmt.Errorf("unknown number option %d", int(o))
+	}
+	return []byte(o.String()), nil
+}
+
+// UnmarshalText reads a number option by name, e.g. "numbersHigh"
+func (o *NumberOption) UnmarshalText(b []byte) error {
+	for option, name := range numberOptionNames {
+		if name == string(b) {
+			*o = option
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown number option %q", b)
+}
+
 // FactoryOpts represents a set of options for the factory to use during
 // generation.
 type FactoryOpts struct {
 	// NonstandardModCombinations tells the factory to generate strokes for the
 	// modifier combinations ctrl-gui, shift-ctrl-gui, ctrl-alt-gui, and
 	// shift-ctrl-alt-gui ("hyper")
-	NonstandardModCombinations bool
+	NonstandardModCombinations bool `json:"nonstandardModCombinations"`
 	// Fingerspellings tells the factory to generate strokes for all
 	// fingerspellings (with the alteration that * is not included in the
 	// fingerspelling, to leave it open for modifier masks)
-	Fingerspellings bool
+	Fingerspellings bool `json:"fingerspellings"`
 	// NumbersLeft tells the factory to generate strokes using # and S-, T-,
 	// P-, H-, A and O
-	NumbersLeft NumberOption
+	NumbersLeft NumberOption `json:"numbersLeft"`
 	// NumberStarsLeft tells the factory to generate strokes using #* and S-,
 	// T-, P-, H-, A and O
-	NumberStarsLeft NumberOption
-	// TODO: NumbersRight and NumberStarsRight?
+	NumberStarsLeft NumberOption `json:"numberStarsLeft"`
+	// NumbersRight tells the factory to generate strokes using # and -F, -P,
+	// -L, -T, -E and -U, which stand in for S-, T-, P-, H-, A and O
+	// respectively (so NumberOptionNumbersHigh gives Plover's -6 to -9)
+	NumbersRight NumberOption `json:"numbersRight"`
+	// NumberStarsRight tells the factory to generate strokes using #* and -F,
+	// -P, -L, -T, -E and -U
+	NumberStarsRight NumberOption `json:"numberStarsRight"`
+	// Symbols tells the factory to generate strokes for the rules' symbol
+	// layer, combining each symbol chord with the layer and modifier masks
+	// the same way as the navigation keys
+	Symbols bool `json:"symbols"`
+	// Media tells the factory to generate strokes for media, volume and
+	// brightness keys, each made of the rules' media layer mask and a media
+	// chord (see DefaultMediaKeys). They are not combined with modifiers.
+	Media bool `json:"media"`
+}
+
+// numberSlots are the positions a number option assigns keys to, in order.
+// On the left hand they are S-, T-, P-, H-, A and O.
+var numberSlots = []byte{'1', '2', '3', '4', '5', '0'}
+
+// numberOptionKeys maps each number option to the Qwerty key each slot
+// generates under it
+var numberOptionKeys = map[NumberOption]map[byte]QwertyKey{
+	NumberOptionNumbers:       {'1': N1, '2': N2, '3': N3, '4': N4, '5': N5, '0': N0},
+	NumberOptionNumbersHigh:   {'1': N6, '2': N7, '3': N8, '4': N9, '5': N5, '0': N0},
+	NumberOptionFunctions:     {'1': F1, '2': F2, '3': F3, '4': F4, '5': F5, '0': F12},
+	NumberOptionFunctionsHigh: {'1': F6, '2': F7, '3': F8, '4': F9, '5': F10, '0': F11},
+}
+
+// numberChords returns every chord the given options generate for numbers, in
+// the order left, left star, right, right star.
+func numberChords(s *System, opts FactoryOpts) []keyChord {
+	left := s.leftNumbers()
+	right := s.rightNumbers()
+	star := s.star()
+	groups := []struct {
+		name   string
+		option NumberOption
+		slots  map[byte]Keymask
+		extra  Keymask
+	}{
+		{"numbersLeft", opts.NumbersLeft, left, 0},
+		{"numberStarsLeft", opts.NumberStarsLeft, left, star},
+		{"numbersRight", opts.NumbersRight, right, 0},
+		{"numberStarsRight", opts.NumberStarsRight, right, star},
+	}
+	chords := make([]keyChord, 0)
+	for _, group := range groups {
+		keys := numberOptionKeys[group.option]
+		for _, slot := range numberSlots {
+			mask, ok := group.slots[slot]
+			if !ok || keys == nil {
+				continue
+			}
+			chords = append(chords, keyChord{
+				name: fmt.Sprintf("%s %s", group.name, keys[slot]),
+				mask: mask | group.extra,
+				key:  keys[slot],
+			})
+		}
+	}
+	return chords
+}
+
+// DefaultFactoryOpts are the options used for rules files that don't declare
+// any (i.e. version 1 rules files)
+var DefaultFactoryOpts = FactoryOpts{
+	NonstandardModCombinations: true,
+	Fingerspellings:            true,
+	NumbersLeft:                NumberOptionNumbers,
+	NumberStarsLeft:            NumberOptionFunctions,
 }
 
 // Factory allows a caller to generate a dictionary, using certain options.
@@ -52,8 +160,6 @@ func NewFactory(opts FactoryOpts) *Factory {
 	return &Factory{opts}
 }
 
-const definitionFmt = "{#%s}{^}{>}"
-
 // Generate tells the receiver to build a dictionary.
 // The base set of definitions will include the following:
 // - Navigation strokes (layer-esc, layer-space, layer-tab, layer-return, layer-home, layer-pageUp, layer-pageDown, layer-end, layer-backspace, layer-delete, layer-up, layer-down, layer-left, layer-right)
@@ -63,118 +169,71 @@ const definitionFmt = "{#%s}{^}{>}"
 // - Fingerspelling strokes (e.g. shift-S, ctrl-S, alt-S, gui-S, shift-ctrl-S, shift-alt-S, shift-gui-S, ctrl-alt-S, alt-gui-S, shift-ctrl-alt-S, shift-alt-gui-S)
 // - Left-hand Number strokes (e.g. shift-1, ctrl-1, alt-1, gui-1, shift-ctrl-1, shift-alt-1, shift-gui-1, ctrl-alt-1, alt-gui-1, shift-ctrl-alt-1, shift-alt-gui-1)
 // - Left-hand Function strokes (replaces Left-hand Number strokes 1-5 and 0 with F1-F5 and F12, respectively)
-func (f *Factory) Generate(r *Rules) *Dictionary {
-	// mods is a map from rules entry (steno keymask) to definition format
-	mods := map[Keymask]QwertyMod{
-		r.Layer:                  "%s",
-		r.Shift:                  Shift,
-		r.Ctrl:                   Ctrl,
-		r.Alt:                    Alt,
-		r.Gui:                    Gui,
-		r.Shift | r.Ctrl:         Shift.apply(string(Ctrl)),
-		r.Shift | r.Alt:          Shift.apply(string(Alt)),
-		r.Shift | r.Gui:          Shift.apply(string(Gui)),
-		r.Ctrl | r.Alt:           Ctrl.apply(string(Alt)),
-		r.Alt | r.Gui:            Alt.apply(string(Gui)),
-		r.Shift | r.Ctrl | r.Alt: Shift.apply(string(Ctrl.apply(string(Alt)))),
-		r.Shift | r.Alt | r.Gui:  Shift.apply(string(Alt.apply(string(Gui)))),
+// - Symbol strokes (e.g. layer-bracketleft, ctrl-bracketleft), from the rules' symbol layer
+// - Right-hand Number and Function strokes, the same as the left-hand ones but using # with -F, -P, -L, -T, -E and -U
+// - Media strokes (e.g. media-XF86AudioPlay), from the rules' media layer
+// It returns an error if one of the rules' templates can't be expanded.
+func (f *Factory) Generate(r *Rules) (*Dictionary, error) {
+	// mods is a map from rules entry (steno keymask) to modifier
+	mods := make(map[Keymask]modifierChord)
+	for _, chord := range r.modifierChords() {
+		if chord.nonstandard && !f.opts.NonstandardModCombinations {
+			continue
+		}
+		mods[chord.mask] = chord
 	}
-	if f.opts.NonstandardModCombinations {
-		mods[r.Ctrl|r.Gui] = Ctrl.apply(string(Gui))
-		mods[r.Shift|r.Ctrl|r.Gui] = Shift.apply(string(Ctrl.apply(string(Gui))))
-		mods[r.Ctrl|r.Alt|r.Gui] = Ctrl.apply(string(Alt.apply(string(Gui))))
-		mods[r.Shift|r.Ctrl|r.Alt|r.Gui] = Shift.apply(string(Ctrl.apply(string(Alt.apply(string(Gui))))))
+	// keys is a map from rules entry (steno keymask) to key
+	keys := make(map[Keymask]keyChord)
+	for _, chord := range r.keyChords() {
+		keys[chord.mask] = chord
 	}
-	// keys is a map from rules entry (steno keymask) to definition string
-	keys := map[Keymask]QwertyKey{
-		r.Escape:    Escape,
-		r.Space:     Space,
-		r.Tab:       Tab,
-		r.Return:    Return,
-		r.Home:      Home,
-		r.PageUp:    PageUp,
-		r.PageDown:  PageDown,
-		r.End:       End,
-		r.Backspace: Backspace,
-		r.Delete:    Delete,
-		r.Left:      Left,
-		r.Up:        Up,
-		r.Down:      Down,
-		r.Right:     Right,
+	for _, chord := range r.fingerspellingChords(f.opts) {
+		keys[chord.mask] = chord
 	}
-	if f.opts.Fingerspellings {
-		for k, q := range allFingerspellings() {
-			keys[k] = q
-		}
+	for _, chord := range numberChords(r.sys(), f.opts) {
+		keys[chord.mask] = chord
 	}
-	switch f.opts.NumbersLeft {
-	case NumberOptionNumbers:
-		keys[Steno1] = N1
-		keys[Steno2] = N2
-		keys[Steno3] = N3
-		keys[Steno4] = N4
-		keys[Steno5] = N5
-		keys[Steno0] = N0
-	case NumberOptionNumbersHigh:
-		keys[Steno1] = N6
-		keys[Steno2] = N7
-		keys[Steno3] = N8
-		keys[Steno4] = N9
-		keys[Steno5] = N5
-		keys[Steno0] = N0
-	case NumberOptionFunctions:
-		keys[Steno1] = F1
-		keys[Steno2] = F2
-		keys[Steno3] = F3
-		keys[Steno4] = F4
-		keys[Steno5] = F5
-		keys[Steno0] = F12
-	case NumberOptionFunctionsHigh:
-		keys[Steno1] = F6
-		keys[Steno2] = F7
-		keys[Steno3] = F8
-		keys[Steno4] = F9
-		keys[Steno5] = F10
-		keys[Steno0] = F11
+	for _, chord := range r.symbolChords(f.opts) {
+		keys[chord.mask] = chord
+	}
+
+	// go through the chords in steno order, so that the same error is always
+	// the one returned
+	modMasks := make([]Keymask, 0, len(mods))
+	for mask := range mods {
+		modMasks = append(modMasks, mask)
 	}
-	switch f.opts.NumberStarsLeft {
-	case NumberOptionNumbers:
-		keys[Steno1|Star] = N1
-		keys[Steno2|Star] = N2
-		keys[Steno3|Star] = N3
-		keys[Steno4|Star] = N4
-		keys[Steno5|Star] = N5
-		keys[Steno0|Star] = N0
-	case NumberOptionNumbersHigh:
-		keys[Steno1|Star] = N6
-		keys[Steno2|Star] = N7
-		keys[Steno3|Star] = N8
-		keys[Steno4|Star] = N9
-		keys[Steno5|Star] = N5
-		keys[Steno0|Star] = N0
-	case NumberOptionFunctions:
-		keys[Steno1|Star] = F1
-		keys[Steno2|Star] = F2
-		keys[Steno3|Star] = F3
-		keys[Steno4|Star] = F4
-		keys[Steno5|Star] = F5
-		keys[Steno0|Star] = F12
-	case NumberOptionFunctionsHigh:
-		keys[Steno1|Star] = F6
-		keys[Steno2|Star] = F7
-		keys[Steno3|Star] = F8
-		keys[Steno4|Star] = F9
-		keys[Steno5|Star] = F10
-		keys[Steno0|Star] = F11
+	sortStrokes(modMasks)
+	keyMasks := make([]Keymask, 0, len(keys))
+	for mask := range keys {
+		keyMasks = append(keyMasks, mask)
 	}
+	sortStrokes(keyMasks)
 
-	d := make(map[*Brief]string)
-	for stenoMod, qwertyMod := range mods {
-		for stenoKey, qwertyKey := range keys {
-			d[SingleStrokeBrief(stenoMod|stenoKey)] = fmt.Sprintf(definitionFmt, qwertyMod.apply(string(qwertyKey)))
+	d := NewSystemDictionary(r.sys())
+	for _, stenoMod := range modMasks {
+		for _, stenoKey := range keyMasks {
+			definition, err := r.definition(mods[stenoMod], keys[stenoKey])
+			if err != nil {
+				return nil, err
+			}
+			d.Add(SingleStrokeBrief(stenoMod|stenoKey), definition)
 		}
 	}
+	for _, chord := range r.mediaChords(f.opts) {
+		definition, err := r.definition(modifierChord{mod: "%s"}, chord)
+		if err != nil {
+			return nil, err
+		}
+		d.Add(SingleStrokeBrief(chord.mask), definition)
+	}
+
+	return d, nil
+}
 
-	dict := Dictionary(d)
-	return &dict
+// sortStrokes sorts the given strokes in steno order
+func sortStrokes(strokes []Keymask) {
+	sort.Slice(strokes, func(i, j int) bool {
+		return strokeLess(strokes[i], strokes[j])
+	})
 }
@@ -0,0 +1,47 @@
+package dictionary
+
+import "testing"
+
+// mustGenerate generates a dictionary from the given rules, failing the test
+// if it can't
+func mustGenerate(t *testing.T, opts FactoryOpts, r *Rules) *Dictionary {
+	t.Helper()
+	d, err := NewFactory(opts).Generate(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestFactoryNumbersRight(t *testing.T) {
+	r := &Rules{
+		Escape: LeftS | LeftK | LeftP,
+		Layer:  LeftS | LeftT | LeftK | LeftP | LeftW | LeftH | LeftR,
+	}
+	d := mustGenerate(t, FactoryOpts{
+		NumbersRight:     NumberOptionNumbersHigh,
+		NumberStarsRight: NumberOptionFunctionsHigh,
+	}, r)
+
+	cases := []struct {
+		stroke   string
+		expected string
+	}{
+		{"STKPWHR-6", "{#6}{^}{>}"},
+		{"STKPWHR-9", "{#9}{^}{>}"},
+		{"#STKPWHREFPLT", ""},
+		{"#STKPWHRE", "{#5}{^}{>}"},
+		{"#STKPWHRU", "{#0}{^}{>}"},
+		{"STKPWHR*7", "{#F7}{^}{>}"},
+		{"#STKPWHR*U", "{#F11}{^}{>}"},
+	}
+	for _, c := range cases {
+		b, err := ParseBrief(c.stroke)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual, _ := d.Lookup(b); actual != c.expected {
+			t.Errorf("expected %s to be %q, got %q", c.stroke, c.expected, actual)
+		}
+	}
+}
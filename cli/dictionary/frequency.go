@@ -0,0 +1,123 @@
+package dictionary
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Frequencies ranks words by how often they are used. The most frequent word
+// has rank 1.
+type Frequencies struct {
+	ranks map[string]int
+}
+
+// NewFrequencies ranks the given words in order, most frequent first. If a
+// word appears more than once, its first rank is kept.
+func NewFrequencies(words []string) *Frequencies {
+	f := &Frequencies{ranks: make(map[string]int, len(words))}
+	for _, word := range words {
+		if _, ok := f.ranks[word]; !ok {
+			f.ranks[word] = len(f.ranks) + 1
+		}
+	}
+	return f
+}
+
+// ParseFrequencies reads a word frequency list. Each line holds a word,
+// optionally followed by a tab and the number of times it is used. If every
+// line has a count, words are ranked by their counts; otherwise they are
+// ranked in the order they appear. Blank lines and lines starting with # are
+// skipped.
+func ParseFrequencies(r io.Reader) (*Frequencies, error) {
+	type counted struct {
+		word  string
+		count int
+	}
+	words := make([]counted, 0)
+	allCounted := true
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, '\t')
+		if i < 0 {
+			allCounted = false
+			words = append(words, counted{word: strings.TrimSpace(line)})
+			continue
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: count %q is not a number", lineNumber, line[i+1:])
+		}
+		words = append(words, counted{word: strings.TrimSpace(line[:i]), count: count})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if allCounted {
+		sort.SliceStable(words, func(i, j int) bool {
+			return words[i].count > words[j].count
+		})
+	}
+	ordered := make([]string, len(words))
+	for i, w := range words {
+		ordered[i] = w.word
+	}
+	return NewFrequencies(ordered), nil
+}
+
+// ReadFrequencyFile reads a word frequency list from the given file (see
+// ParseFrequencies).
+func ReadFrequencyFile(filename string) (*Frequencies, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	freq, err := ParseFrequencies(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return freq, nil
+}
+
+// Len returns the number of words the receiver ranks
+func (f *Frequencies) Len() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.ranks)
+}
+
+// Rank returns the rank of the given word, and whether the receiver ranks it
+// at all. A word that is not ranked as written is looked up again in lower
+// case. A nil receiver ranks nothing.
+func (f *Frequencies) Rank(word string) (int, bool) {
+	if f == nil {
+		return 0, false
+	}
+	if rank, ok := f.ranks[word]; ok {
+		return rank, true
+	}
+	rank, ok := f.ranks[strings.ToLower(word)]
+	return rank, ok
+}
+
+// RankLess orders two optional ranks, putting ranked words before unranked
+// ones and more frequent words first. It returns false if the two are equal.
+func RankLess(a int, aOK bool, b int, bOK bool) bool {
+	if aOK != bOK {
+		return aOK
+	}
+	return aOK && a < b
+}
@@ -0,0 +1,42 @@
+package dictionary
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFrequencies(t *testing.T) {
+	f, err := ParseFrequencies(strings.NewReader("# most frequent first\nthe\nof\n\nand\nthe\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Len() != 3 {
+		t.Errorf("expected 3 words, got %d", f.Len())
+	}
+	cases := map[string]int{"the": 1, "of": 2, "and": 3, "The": 1}
+	for word, expected := range cases {
+		if rank, ok := f.Rank(word); !ok || rank != expected {
+			t.Errorf("expected %s to have rank %d, got %d (%v)", word, expected, rank, ok)
+		}
+	}
+	if _, ok := f.Rank("zebra"); ok {
+		t.Errorf("expected zebra not to be ranked")
+	}
+
+	f, err = ParseFrequencies(strings.NewReader("of\t20\nthe\t30\nand\t10\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rank, _ := f.Rank("the"); rank != 1 {
+		t.Errorf("expected counts to decide the ranks, got the=%d", rank)
+	}
+
+	if _, err := ParseFrequencies(strings.NewReader("the\tlots\n")); err == nil {
+		t.Errorf("expected a count that is not a number to be an error")
+	}
+
+	var none *Frequencies
+	if _, ok := none.Rank("the"); ok {
+		t.Errorf("expected a nil list to rank nothing")
+	}
+}
@@ -0,0 +1,116 @@
+package dictionary
+
+import "strings"
+
+// keysymNames are the X11 keysym names Plover accepts in key combos
+var keysymNames = [][]string{
+	// Latin-1 characters
+	{
+		"space", "exclam", "quotedbl", "numbersign", "dollar", "percent",
+		"ampersand", "apostrophe", "parenleft", "parenright", "asterisk",
+		"plus", "comma", "minus", "period", "slash", "0", "1", "2", "3", "4",
+		"5", "6", "7", "8", "9", "colon", "semicolon", "less", "equal",
+		"greater", "question", "at", "A", "B", "C", "D", "E", "F", "G", "H",
+		"I", "J", "K", "L", "M", "N", "O", "P", "Q", "R", "S", "T", "U", "V",
+		"W", "X", "Y", "Z", "bracketleft", "backslash", "bracketright",
+		"asciicircum", "underscore", "grave", "a", "b", "c", "d", "e", "f",
+		"g", "h", "i", "j", "k", "l", "m", "n", "o", "p", "q", "r", "s", "t",
+		"u", "v", "w", "x", "y", "z", "braceleft", "bar", "braceright",
+		"asciitilde", "nobreakspace", "exclamdown", "cent", "sterling",
+		"currency", "yen", "brokenbar", "section", "diaeresis", "copyright",
+		"ordfeminine", "guillemotleft", "notsign", "hyphen", "registered",
+		"macron", "degree", "plusminus", "twosuperior", "threesuperior",
+		"acute", "mu", "paragraph", "periodcentered", "cedilla", "onesuperior",
+		"masculine", "guillemotright", "onequarter", "onehalf",
+		"threequarters", "questiondown", "Agrave", "Aacute", "Acircumflex",
+		"Atilde", "Adiaeresis", "Aring", "AE", "Ccedilla", "Egrave", "Eacute",
+		"Ecircumflex", "Ediaeresis", "Igrave", "Iacute", "Icircumflex",
+		"Idiaeresis", "ETH", "Ntilde", "Ograve", "Oacute", "Ocircumflex",
+		"Otilde", "Odiaeresis", "multiply", "Oslash", "Ugrave", "Uacute",
+		"Ucircumflex", "Udiaeresis", "Yacute", "THORN", "ssharp", "agrave",
+		"aacute", "acircumflex", "atilde", "adiaeresis", "aring", "ae",
+		"ccedilla", "egrave", "eacute", "ecircumflex", "ediaeresis", "igrave",
+		"iacute", "icircumflex", "idiaeresis", "eth", "ntilde", "ograve",
+		"oacute", "ocircumflex", "otilde", "odiaeresis", "division", "oslash",
+		"ugrave", "uacute", "ucircumflex", "udiaeresis", "yacute", "thorn",
+		"ydiaeresis",
+	},
+	// function and navigation keys
+	{
+		"BackSpace", "Tab", "Linefeed", "Clear", "Return", "Pause",
+		"Scroll_Lock", "Sys_Req", "Escape", "Delete", "Home", "Left", "Up",
+		"Right", "Down", "Prior", "Page_Up", "Next", "Page_Down", "End",
+		"Begin", "Select", "Print", "Execute", "Insert", "Undo", "Redo",
+		"Menu", "Find", "Cancel", "Help", "Break", "Mode_switch", "Num_Lock",
+	},
+	// the numeric keypad
+	{
+		"KP_Space", "KP_Tab", "KP_Enter", "KP_F1", "KP_F2", "KP_F3", "KP_F4",
+		"KP_Home", "KP_Left", "KP_Up", "KP_Right", "KP_Down", "KP_Prior",
+		"KP_Page_Up", "KP_Next", "KP_Page_Down", "KP_End", "KP_Begin",
+		"KP_Insert", "KP_Delete", "KP_Equal", "KP_Multiply", "KP_Add",
+		"KP_Separator", "KP_Subtract", "KP_Decimal", "KP_Divide", "KP_0",
+		"KP_1", "KP_2", "KP_3", "KP_4", "KP_5", "KP_6", "KP_7", "KP_8", "KP_9",
+	},
+	// F-keys
+	{
+		"F1", "F2", "F3", "F4", "F5", "F6", "F7", "F8", "F9", "F10", "F11",
+		"F12", "F13", "F14", "F15", "F16", "F17", "F18", "F19", "F20", "F21",
+		"F22", "F23", "F24", "F25", "F26", "F27", "F28", "F29", "F30", "F31",
+		"F32", "F33", "F34", "F35",
+	},
+	// modifiers
+	{
+		"Shift_L", "Shift_R", "Control_L", "Control_R", "Caps_Lock",
+		"Shift_Lock", "Meta_L", "Meta_R", "Alt_L", "Alt_R", "Super_L",
+		"Super_R", "Hyper_L", "Hyper_R", "ISO_Level3_Shift",
+	},
+	// media and hardware keys
+	{
+		"XF86AudioLowerVolume", "XF86AudioMute", "XF86AudioRaiseVolume",
+		"XF86AudioMicMute", "XF86AudioPlay", "XF86AudioPause", "XF86AudioStop",
+		"XF86AudioPrev", "XF86AudioNext", "XF86AudioRecord", "XF86AudioRewind",
+		"XF86AudioForward", "XF86AudioRepeat", "XF86AudioRandomPlay",
+		"XF86AudioMedia", "XF86Eject", "XF86MonBrightnessUp",
+		"XF86MonBrightnessDown", "XF86KbdBrightnessUp",
+		"XF86KbdBrightnessDown", "XF86KbdLightOnOff", "XF86Display",
+		"XF86ScreenSaver", "XF86Sleep", "XF86PowerOff", "XF86WakeUp",
+		"XF86Back", "XF86Forward", "XF86Refresh", "XF86Search", "XF86HomePage",
+		"XF86Favorites", "XF86Mail", "XF86Calculator", "XF86Explorer",
+		"XF86MyComputer", "XF86Documents", "XF86Launch0", "XF86Launch1",
+		"XF86Copy", "XF86Cut", "XF86Paste", "XF86Open", "XF86Close", "XF86New",
+		"XF86Save", "XF86Reload",
+	},
+}
+
+// keysyms holds every keysym name, and lowerKeysyms maps each of them,
+// lowercased, to its spelling. Plover matches key names without regard to
+// case, but some names (e.g. Agrave and agrave) only differ by case, so exact
+// matches are tried first.
+var keysyms, lowerKeysyms = func() (map[string]bool, map[string]string) {
+	exact := make(map[string]bool)
+	lower := make(map[string]string)
+	for _, names := range keysymNames {
+		for _, name := range names {
+			exact[name] = true
+			lower[strings.ToLower(name)] = name
+		}
+	}
+	return exact, lower
+}()
+
+// CanonicalKeysym returns the canonical spelling of the given X11 keysym name
+// (e.g. "page_up" gives "Page_Up"), and whether Plover accepts it at all.
+func CanonicalKeysym(name string) (string, bool) {
+	if keysyms[name] {
+		return name, true
+	}
+	canonical, ok := lowerKeysyms[strings.ToLower(name)]
+	return canonical, ok
+}
+
+// IsKeysym returns true if Plover accepts the given X11 keysym name
+func IsKeysym(name string) bool {
+	_, ok := CanonicalKeysym(name)
+	return ok
+}
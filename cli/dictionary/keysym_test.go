@@ -0,0 +1,27 @@
+package dictionary
+
+import "testing"
+
+func TestCanonicalKeysym(t *testing.T) {
+	cases := []struct {
+		in        string
+		canonical string
+		ok        bool
+	}{
+		{"bracketleft", "bracketleft", true},
+		{"page_up", "Page_Up", true},
+		{"KP_ADD", "KP_Add", true},
+		{"Agrave", "Agrave", true},
+		{"agrave", "agrave", true},
+		{"XF86AudioPlay", "XF86AudioPlay", true},
+		{"F35", "F35", true},
+		{"bracket", "", false},
+		{"F36", "", false},
+	}
+	for _, c := range cases {
+		canonical, ok := CanonicalKeysym(c.in)
+		if ok != c.ok || canonical != c.canonical {
+			t.Errorf("expected %s to give %q, %v, got %q, %v", c.in, c.canonical, c.ok, canonical, ok)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package dictionary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// loadBatchSize is the number of entries each goroutine parses at a time
+const loadBatchSize = 4096
+
+// loadBatch is a run of consecutive entries from a JSON dictionary
+type loadBatch struct {
+	index        int
+	strokes      []string
+	translations []string
+	briefs       []*Brief
+	err          error
+}
+
+func (b *loadBatch) parse(s *System) {
+	b.briefs = make([]*Brief, len(b.strokes))
+	for i, stroke := range b.strokes {
+		brief, err := s.ParseBrief(stroke)
+		if err != nil {
+			b.err = err
+			return
+		}
+		b.briefs[i] = brief
+	}
+}
+
+// ReadSystemJSON reads a Plover JSON dictionary whose strokes belong to the
+// given system. Entries are streamed out of the reader and their strokes are
+// parsed on several goroutines at once. As in Plover, when two entries come
+// out as the same brief, the later one wins.
+func ReadSystemJSON(r io.Reader, s *System) (*Dictionary, error) {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	workers := runtime.GOMAXPROCS(0)
+	todo := make(chan *loadBatch, workers)
+	done := make(chan *loadBatch, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range todo {
+				batch.parse(s)
+				done <- batch
+			}
+		}()
+	}
+	batches := make([]*loadBatch, 0)
+	collected := make(chan struct{})
+	go func() {
+		for batch := range done {
+			batches = append(batches, batch)
+		}
+		close(collected)
+	}()
+
+	decodeErr := decodeBatches(dec, todo)
+	close(todo)
+	wg.Wait()
+	close(done)
+	<-collected
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	sort.Slice(batches, func(i, j int) bool {
+		return batches[i].index < batches[j].index
+	})
+	d := NewSystemDictionary(s)
+	for _, batch := range batches {
+		if batch.err != nil {
+			return nil, batch.err
+		}
+	}
+	for _, batch := range batches {
+		for i, brief := range batch.briefs {
+			d.Add(brief, batch.translations[i])
+		}
+	}
+	return d, nil
+}
+
+// decodeBatches reads the entries of a JSON object from the given decoder,
+// whose opening brace has already been read, and sends them to todo in
+// batches.
+func decodeBatches(dec *json.Decoder, todo chan<- *loadBatch) error {
+	batch := &loadBatch{}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		stroke, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("expected a stroke, got %v", token)
+		}
+		var translation string
+		if err := dec.Decode(&translation); err != nil {
+			return fmt.Errorf("stroke %s: %v", stroke, err)
+		}
+		batch.strokes = append(batch.strokes, stroke)
+		batch.translations = append(batch.translations, translation)
+		if len(batch.strokes) == loadBatchSize {
+			todo <- batch
+			batch = &loadBatch{index: batch.index + 1}
+		}
+	}
+	if len(batch.strokes) > 0 {
+		todo <- batch
+	}
+	return expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %s, got %v", delim, token)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package dictionary
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadSystemJSON(t *testing.T) {
+	d, err := ReadSystemJSON(strings.NewReader(`{
+		"SHRFRLG": "{#Return}",
+		"TEFT": "test",
+		"SHR-FRLG": "{#Return}{^}{-|}"
+	}`), English)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", d.Len())
+	}
+	// SHRFRLG and SHR-FRLG are the same brief, and the later entry wins
+	if translation, _ := d.Lookup(SingleStrokeBrief(LeftS | LeftH | LeftR | RightF | RightR | RightL | RightG)); translation != "{#Return}{^}{-|}" {
+		t.Errorf("expected the later entry to win, got %q", translation)
+	}
+
+	for _, in := range []string{`{"XYZ": "nope"}`, `{"TEFT": 1}`, `["TEFT"]`, `{"TEFT": "test"`} {
+		if _, err := ReadSystemJSON(strings.NewReader(in), English); err == nil {
+			t.Errorf("expected %s to be an error", in)
+		}
+	}
+}
+
+func TestParseStrokeDoesNotAllocate(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := ParseStroke("12K3W4R50*EU6R7B8G9SDZ"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected ParseStroke not to allocate, got %v allocations", allocs)
+	}
+}
+
+func BenchmarkParseStroke(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseStroke("STKPWHRAO*EUFRPBLGTSDZ"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkReadMainDictionary reads a dictionary the size of Plover's main.json
+// (about 150k entries). Set STENO_MAIN_JSON to the path of a real main.json to
+// read that instead of a generated one.
+func BenchmarkReadMainDictionary(b *testing.B) {
+	filename := os.Getenv("STENO_MAIN_JSON")
+	if filename == "" {
+		dir, err := ioutil.TempDir("", "steno")
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		filename = filepath.Join(dir, "main.json")
+		writeRandomDictionary(b, filename, 150000)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadFile(filename); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func writeRandomDictionary(b *testing.B, filename string, entries int) {
+	b.Helper()
+	r := rand.New(rand.NewSource(1))
+	raw := make(map[string]string, entries)
+	for i := 0; i < entries; i++ {
+		strokes := make([]Keymask, 1+r.Intn(3))
+		for j := range strokes {
+			strokes[j] = Keymask(r.Int63n(int64(Num) << 1))
+		}
+		raw[NewBrief(strokes...).String()] = strings.Repeat("x", 1+r.Intn(12))
+	}
+	out, err := json.Marshal(raw)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename, out, 0644); err != nil {
+		b.Fatal(err)
+	}
+}
@@ -0,0 +1,63 @@
+package dictionary
+
+// defaultMediaKey is a default media chord, named by its keys
+type defaultMediaKey struct {
+	name   string
+	keysym QwertyKey
+	keys   []string
+}
+
+var defaultMediaKeys = []defaultMediaKey{
+	{"stop", "XF86AudioStop", []string{"S-"}},
+	{"previous", "XF86AudioPrev", []string{"T-"}},
+	{"play", "XF86AudioPlay", []string{"P-"}},
+	{"next", "XF86AudioNext", []string{"H-"}},
+	{"volumeDown", "XF86AudioLowerVolume", []string{"K-"}},
+	{"mute", "XF86AudioMute", []string{"W-"}},
+	{"volumeUp", "XF86AudioRaiseVolume", []string{"R-"}},
+	{"brightnessDown", "XF86MonBrightnessDown", []string{"K-", "A-"}},
+	{"micMute", "XF86AudioMicMute", []string{"W-", "A-"}},
+	{"brightnessUp", "XF86MonBrightnessUp", []string{"R-", "A-"}},
+}
+
+// DefaultMediaKeys returns the media chords the factory uses when the rules
+// don't list any, for the given system. Each is combined with the rules' media
+// layer mask. They are laid out on the left hand: the top row is previous,
+// play and next, the bottom row is volume down, mute and volume up, and adding
+// A- moves the bottom row to brightness down, microphone mute and brightness
+// up. Chords that use a key the system doesn't have are left out.
+func DefaultMediaKeys(system *System) []KeyBinding {
+	bindings := make([]KeyBinding, 0, len(defaultMediaKeys))
+	for _, k := range defaultMediaKeys {
+		var mask Keymask
+		for _, name := range k.keys {
+			key, err := system.Key(name)
+			if err != nil {
+				mask = 0
+				break
+			}
+			mask |= key
+		}
+		if mask != 0 {
+			bindings = append(bindings, KeyBinding{k.name, k.keysym, mask})
+		}
+	}
+	return bindings
+}
+
+// mediaChords returns the strokes of the receiver's media layer, if the given
+// options turn it on. Each stroke already includes the media layer mask.
+func (r *Rules) mediaChords(opts FactoryOpts) []keyChord {
+	if !opts.Media {
+		return nil
+	}
+	media := r.Media
+	if media == nil {
+		media = DefaultMediaKeys(r.sys())
+	}
+	chords := make([]keyChord, len(media))
+	for i, k := range media {
+		chords[i] = keyChord{"media+" + k.Name, r.MediaLayer | k.Mask, k.Keysym}
+	}
+	return chords
+}
@@ -0,0 +1,59 @@
+package dictionary
+
+// MergeConflict is a stroke that both sides of a three-way merge changed, in
+// different ways. A translation is blank if that side doesn't define the
+// stroke.
+type MergeConflict struct {
+	Brief string `json:"brief"`
+	Base  string `json:"base,omitempty"`
+	A     string `json:"a,omitempty"`
+	B     string `json:"b,omitempty"`
+}
+
+// Merge3 merges two dictionaries that were both copied from a common
+// ancestor, base, taking every change either side made to it (including
+// adding and removing strokes). If both sides changed a stroke in the same
+// way, the change is taken once. If they changed it in different ways, A's
+// translation is kept and the stroke is returned as a conflict. Conflicts
+// are in steno order.
+func Merge3(base, a, b *Dictionary) (*Dictionary, []MergeConflict) {
+	merged := NewSystemDictionary(a.system)
+	conflicts := make([]MergeConflict, 0)
+	seen := make(map[string]bool)
+	briefs := make([]*Brief, 0)
+	for _, d := range []*Dictionary{base, a, b} {
+		d.Each(func(brief *Brief, translation string) bool {
+			if !seen[brief.key()] {
+				seen[brief.key()] = true
+				briefs = append(briefs, brief)
+			}
+			return true
+		})
+	}
+	sortBriefs(briefs)
+
+	for _, brief := range briefs {
+		baseTranslation, baseOK := base.Lookup(brief)
+		aTranslation, aOK := a.Lookup(brief)
+		bTranslation, bOK := b.Lookup(brief)
+		aChanged := aOK != baseOK || aTranslation != baseTranslation
+		bChanged := bOK != baseOK || bTranslation != baseTranslation
+
+		translation, ok := aTranslation, aOK
+		if bChanged && !aChanged {
+			translation, ok = bTranslation, bOK
+		}
+		if aChanged && bChanged && (aOK != bOK || aTranslation != bTranslation) {
+			conflicts = append(conflicts, MergeConflict{
+				Brief: a.system.BriefString(brief),
+				Base:  baseTranslation,
+				A:     aTranslation,
+				B:     bTranslation,
+			})
+		}
+		if ok {
+			merged.Add(brief, translation)
+		}
+	}
+	return merged, conflicts
+}
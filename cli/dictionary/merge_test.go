@@ -0,0 +1,48 @@
+package dictionary
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	base := newTestDictionary(t, map[string]string{
+		"KAT":  "cat",
+		"TKOG": "dog",
+		"-T":   "the",
+		"-F":   "of",
+	})
+	a := newTestDictionary(t, map[string]string{
+		"KAT":  "cat",
+		"TKOG": "doggo",
+		"-T":   "the",
+		"-F":   "of",
+		"SKWR": "and",
+	})
+	b := newTestDictionary(t, map[string]string{
+		"KAT":  "kitty",
+		"TKOG": "hound",
+		"-T":   "the",
+		"SKWR": "and",
+	})
+
+	merged, conflicts := Merge3(base, a, b)
+	expected := map[string]string{
+		"KAT":  "kitty",
+		"TKOG": "doggo",
+		"-T":   "the",
+		"SKWR": "and",
+	}
+	actual := make(map[string]string)
+	merged.Each(func(brief *Brief, translation string) bool {
+		actual[merged.System().BriefString(brief)] = translation
+		return true
+	})
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+	expectedConflicts := []MergeConflict{{Brief: "TKOG", Base: "dog", A: "doggo", B: "hound"}}
+	if !reflect.DeepEqual(conflicts, expectedConflicts) {
+		t.Errorf("expected conflicts %v, got %v", expectedConflicts, conflicts)
+	}
+}
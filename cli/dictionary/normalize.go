@@ -0,0 +1,132 @@
+package dictionary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// RawEntry is a dictionary entry exactly as it appears in a file, before its
+// stroke has been parsed.
+type RawEntry struct {
+	Stroke      string `json:"stroke"`
+	Translation string `json:"translation"`
+}
+
+func (e RawEntry) String() string {
+	return fmt.Sprintf("%q: %q", e.Stroke, e.Translation)
+}
+
+// NormalizeReport describes what Normalize did to a set of raw entries.
+type NormalizeReport struct {
+	// Rewritten maps each stroke that was not canonical to its canonical form
+	Rewritten map[string]string
+	// Merged holds groups of entries whose strokes came out identical and
+	// whose translations agreed
+	Merged [][]RawEntry
+	// Conflicts holds groups of entries whose strokes came out identical but
+	// whose translations disagreed. The first entry of each group is the one
+	// that was kept.
+	Conflicts [][]RawEntry
+}
+
+// UnparseableError lists every raw entry whose stroke could not be parsed
+type UnparseableError struct {
+	Entries []RawEntry
+	Errs    []error
+}
+
+func (e *UnparseableError) Error() string {
+	lines := make([]string, len(e.Entries))
+	for i, entry := range e.Entries {
+		lines[i] = fmt.Sprintf("%s: %v", entry, e.Errs[i])
+	}
+	return fmt.Sprintf("%d entries could not be parsed:\n%s", len(e.Entries), strings.Join(lines, "\n"))
+}
+
+// ReadRawFile reads a Plover JSON dictionary without parsing its strokes.
+func ReadRawFile(filename string) (map[string]string, error) {
+	inBytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]string
+	if err = json.Unmarshal(inBytes, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return raw, nil
+}
+
+// Normalize parses every stroke in the given raw entries and rewrites it into
+// canonical steno order. Entries whose strokes come out identical are merged.
+// When their translations disagree, the entry that was already canonical wins
+// (or else the first one in sorted order), and the group is reported as a
+// conflict. If any stroke cannot be parsed, Normalize returns an
+// *UnparseableError.
+func Normalize(raw map[string]string, s *System) (*Dictionary, *NormalizeReport, error) {
+	strokes := make([]string, 0, len(raw))
+	for stroke := range raw {
+		strokes = append(strokes, stroke)
+	}
+	sort.Strings(strokes)
+
+	report := &NormalizeReport{
+		Rewritten: make(map[string]string),
+		Merged:    make([][]RawEntry, 0),
+		Conflicts: make([][]RawEntry, 0),
+	}
+	unparseable := &UnparseableError{}
+	groups := make(map[string][]RawEntry)
+	briefs := make(map[string]*Brief)
+	keys := make([]string, 0, len(raw))
+	for _, stroke := range strokes {
+		entry := RawEntry{Stroke: stroke, Translation: raw[stroke]}
+		brief, err := s.ParseBrief(stroke)
+		if err != nil {
+			unparseable.Entries = append(unparseable.Entries, entry)
+			unparseable.Errs = append(unparseable.Errs, err)
+			continue
+		}
+		key := s.BriefString(brief)
+		if key != stroke {
+			report.Rewritten[stroke] = key
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+			briefs[key] = brief
+		}
+		if key == stroke {
+			// the canonical entry goes first, so that it wins
+			groups[key] = append([]RawEntry{entry}, groups[key]...)
+		} else {
+			groups[key] = append(groups[key], entry)
+		}
+	}
+	if len(unparseable.Entries) > 0 {
+		return nil, nil, unparseable
+	}
+
+	sort.Strings(keys)
+	d := NewSystemDictionary(s)
+	for _, key := range keys {
+		group := groups[key]
+		d.Add(briefs[key], group[0].Translation)
+		if len(group) == 1 {
+			continue
+		}
+		agree := true
+		for _, entry := range group[1:] {
+			if entry.Translation != group[0].Translation {
+				agree = false
+			}
+		}
+		if agree {
+			report.Merged = append(report.Merged, group)
+		} else {
+			report.Conflicts = append(report.Conflicts, group)
+		}
+	}
+	return d, report, nil
+}
@@ -0,0 +1,53 @@
+package dictionary
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	d, report, err := Normalize(map[string]string{
+		"SHR-FRLG":  "{#Return}{^}{-|}",
+		"SHRFRLG":   "{#Return}",
+		"shr*frlg":  "{#Control_L(Return)}{^}{-|}",
+		"SHR*FRLG":  "{#Control_L(Return)}{^}{-|}",
+		"#H-F":      "{#4}",
+		"TKPWEUT/H": "git h",
+	}, English)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Len() != 4 {
+		t.Errorf("expected 4 entries, got %d", d.Len())
+	}
+	if report.Rewritten["SHRFRLG"] != "SHR-FRLG" {
+		t.Errorf("expected SHRFRLG to be rewritten to SHR-FRLG, got %q", report.Rewritten["SHRFRLG"])
+	}
+	if report.Rewritten["#H-F"] != "4-6" {
+		t.Errorf("expected #H-F to be rewritten to 4-6, got %q", report.Rewritten["#H-F"])
+	}
+	if len(report.Merged) != 1 || report.Merged[0][0].Stroke != "SHR*FRLG" {
+		t.Errorf("expected SHR*FRLG to be merged, got %v", report.Merged)
+	}
+	if len(report.Conflicts) != 1 {
+		t.Fatalf("expected 1 conflict, got %d", len(report.Conflicts))
+	}
+	if kept := report.Conflicts[0][0]; kept.Stroke != "SHR-FRLG" {
+		t.Errorf("expected the canonical entry to be kept, got %s", kept)
+	}
+	if translation, _ := d.Lookup(SingleStrokeBrief(LeftS | LeftH | LeftR | RightF | RightR | RightL | RightG)); translation != "{#Return}{^}{-|}" {
+		t.Errorf("expected SHR-FRLG to keep its translation, got %q", translation)
+	}
+}
+
+func TestNormalizeUnparseable(t *testing.T) {
+	_, _, err := Normalize(map[string]string{
+		"SHR-FRLG": "{#Return}",
+		"XYZ":      "nope",
+	}, English)
+	unparseable, ok := err.(*UnparseableError)
+	if !ok {
+		t.Fatalf("expected an UnparseableError, got %v", err)
+	}
+	if len(unparseable.Entries) != 1 || unparseable.Entries[0].Stroke != "XYZ" {
+		t.Errorf("expected XYZ to be the offending entry, got %v", unparseable.Entries)
+	}
+}
@@ -0,0 +1,412 @@
+package dictionary
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// RTF/CRE is the dictionary exchange format used by Eclipse, Case CATalyst
+// and other CAT software. Each entry is a `{\*\cxs STROKE}` group followed by
+// its translation, with control words standing in for Plover's formatting
+// operators.
+
+type rtfTokenKind int
+
+const (
+	rtfGroupStart rtfTokenKind = iota
+	rtfGroupEnd
+	rtfControl
+	rtfText
+)
+
+type rtfToken struct {
+	kind rtfTokenKind
+	// word is the name of a control word, or the character of a control
+	// symbol (e.g. "*")
+	word string
+	text string
+}
+
+// tokenizeRTF splits the given RTF document into groups, control words and
+// runs of text. Hex escapes and \u escapes are decoded into text.
+func tokenizeRTF(in string) ([]rtfToken, error) {
+	tokens := make([]rtfToken, 0)
+	text := new(strings.Builder)
+	flushText := func() {
+		if text.Len() > 0 {
+			tokens = append(tokens, rtfToken{kind: rtfText, text: text.String()})
+			text.Reset()
+		}
+	}
+	// skip is the number of fallback characters to drop after a \u escape
+	skip := 0
+	var highSurrogate rune
+
+	for i := 0; i < len(in); i++ {
+		c := in[i]
+		switch c {
+		case '{':
+			flushText()
+			tokens = append(tokens, rtfToken{kind: rtfGroupStart})
+		case '}':
+			flushText()
+			tokens = append(tokens, rtfToken{kind: rtfGroupEnd})
+		case '\r', '\n':
+			// raw line breaks are not significant in RTF
+		case '\\':
+			if i+1 >= len(in) {
+				return nil, fmt.Errorf("RTF ends with a dangling backslash")
+			}
+			next := in[i+1]
+			switch {
+			case next == '\\' || next == '{' || next == '}':
+				text.WriteByte(next)
+				i++
+			case next == '\'':
+				if i+3 >= len(in) {
+					return nil, fmt.Errorf("RTF hex escape at position %d is truncated", i)
+				}
+				v, err := strconv.ParseUint(in[i+2:i+4], 16, 8)
+				if err != nil {
+					return nil, fmt.Errorf("RTF hex escape at position %d is invalid: %v", i, err)
+				}
+				i += 3
+				if skip > 0 {
+					skip--
+					continue
+				}
+				text.WriteRune(rune(v))
+			case isASCIILetter(next):
+				j := i + 1
+				for j < len(in) && isASCIILetter(in[j]) {
+					j++
+				}
+				word := in[i+1 : j]
+				k := j
+				if k < len(in) && (in[k] == '-' || isASCIIDigit(in[k])) {
+					k++
+					for k < len(in) && isASCIIDigit(in[k]) {
+						k++
+					}
+				}
+				param := in[j:k]
+				if k < len(in) && in[k] == ' ' {
+					k++
+				}
+				i = k - 1
+
+				if word == "u" && param != "" {
+					n, err := strconv.Atoi(param)
+					if err != nil {
+						return nil, fmt.Errorf("RTF unicode escape at position %d is invalid: %v", i, err)
+					}
+					if n < 0 {
+						n += 65536
+					}
+					r := rune(n)
+					switch {
+					case utf16.IsSurrogate(r) && highSurrogate == 0:
+						highSurrogate = r
+					case highSurrogate != 0:
+						text.WriteRune(utf16.DecodeRune(highSurrogate, r))
+						highSurrogate = 0
+					default:
+						text.WriteRune(r)
+					}
+					skip = 1
+					continue
+				}
+				flushText()
+				tokens = append(tokens, rtfToken{kind: rtfControl, word: word})
+			default:
+				flushText()
+				tokens = append(tokens, rtfToken{kind: rtfControl, word: string(next)})
+				i++
+			}
+		default:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			text.WriteByte(c)
+		}
+	}
+	flushText()
+	return tokens, nil
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isASCIIDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// matchingGroupEnd returns the index of the token that closes the group opened
+// at tokens[start].
+func matchingGroupEnd(tokens []rtfToken, start int) int {
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		switch tokens[i].kind {
+		case rtfGroupStart:
+			depth++
+		case rtfGroupEnd:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+func rtfTokensText(tokens []rtfToken) string {
+	b := new(strings.Builder)
+	for _, t := range tokens {
+		if t.kind == rtfText {
+			b.WriteString(t.text)
+		}
+	}
+	return b.String()
+}
+
+// rtfAttach marks the position of a \cxds in a translation that is still
+// being built
+const rtfAttach = "\x00"
+
+// ParseRTF reads an RTF/CRE document into a dictionary, using the English
+// system. Translations are converted to Plover's formatting operators.
+func ParseRTF(in string) (*Dictionary, error) {
+	return ParseSystemRTF(in, English)
+}
+
+// ParseSystemRTF reads an RTF/CRE document into a dictionary whose strokes
+// belong to the given system.
+func ParseSystemRTF(in string, s *System) (*Dictionary, error) {
+	tokens, err := tokenizeRTF(in)
+	if err != nil {
+		return nil, err
+	}
+
+	d := NewSystemDictionary(s)
+	stroke := ""
+	translation := new(strings.Builder)
+	flush := func() error {
+		if stroke == "" {
+			return nil
+		}
+		brief, err := s.ParseBrief(stroke)
+		if err != nil {
+			return err
+		}
+		d.Add(brief, rtfAttachToPlover(strings.TrimSpace(translation.String())))
+		stroke = ""
+		translation.Reset()
+		return nil
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.kind {
+		case rtfGroupStart:
+			end := matchingGroupEnd(tokens, i)
+			// ignorable destinations look like {\*\name ...}
+			if i+2 < end && tokens[i+1].kind == rtfControl && tokens[i+1].word == "*" && tokens[i+2].kind == rtfControl {
+				inner := strings.TrimSpace(rtfTokensText(tokens[i+3 : end]))
+				switch tokens[i+2].word {
+				case "cxs":
+					if err := flush(); err != nil {
+						return nil, err
+					}
+					stroke = inner
+				case "cxplovermeta":
+					if stroke != "" {
+						translation.WriteString("{" + inner + "}")
+					}
+				case "cxplovermacro":
+					if stroke != "" {
+						translation.WriteString("=" + inner)
+					}
+				}
+				i = end
+				continue
+			}
+			if i+1 < end && tokens[i+1].kind == rtfControl {
+				switch tokens[i+1].word {
+				case "cxp":
+					if stroke != "" {
+						translation.WriteString("{" + strings.TrimSpace(rtfTokensText(tokens[i+2:end])) + "}")
+					}
+					i = end
+				case "cxfing":
+					if stroke != "" {
+						translation.WriteString("{&" + translationEscaper.Replace(rtfTokensText(tokens[i+2:end])) + "}")
+					}
+					i = end
+				case "fonttbl", "colortbl", "stylesheet", "info":
+					i = end
+				}
+			}
+		case rtfControl:
+			if stroke == "" {
+				continue
+			}
+			switch t.word {
+			case "cxds":
+				translation.WriteString(rtfAttach)
+			case "cxfc":
+				translation.WriteString("{-|}")
+			case "cxfl":
+				translation.WriteString("{>}")
+			case "par":
+				translation.WriteString("{^\n^}")
+			case "tab":
+				translation.WriteString("{^\t^}")
+			case "_":
+				translation.WriteString("-")
+			case "~":
+				translation.WriteString(" ")
+			}
+		case rtfText:
+			if stroke != "" {
+				translation.WriteString(translationEscaper.Replace(t.text))
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// rtfAttachToPlover replaces the \cxds markers in the given translation with
+// Plover's attach operators, folding them into prefixes and suffixes where it
+// can (e.g. `\cxds ing` becomes `{^ing}`).
+func rtfAttachToPlover(in string) string {
+	segments := strings.Split(in, rtfAttach)
+	attachable := func(s string) bool {
+		return s != "" && !strings.ContainsAny(s, "{} ")
+	}
+	switch {
+	case len(segments) == 1:
+		return in
+	case len(segments) == 2 && segments[0] == "" && attachable(segments[1]):
+		return "{^" + segments[1] + "}"
+	case len(segments) == 2 && segments[1] == "" && attachable(segments[0]):
+		return "{" + segments[0] + "^}"
+	case len(segments) == 3 && segments[0] == "" && segments[2] == "" && attachable(segments[1]):
+		return "{^" + segments[1] + "^}"
+	}
+	return strings.Join(segments, "{^}")
+}
+
+const rtfHeader = `{\rtf1\ansi{\*\cxrev100}\cxdict{\*\cxsystem steno}{\stylesheet{\s0 Normal;}}` + "\r\n"
+
+// WriteRTF writes the receiver to w as an RTF/CRE document. Plover operators
+// that have no RTF/CRE equivalent (such as key combos) are kept in
+// `{\*\cxplovermeta ...}` groups, which other software will ignore.
+func (d *Dictionary) WriteRTF(w io.Writer) error {
+	if _, err := io.WriteString(w, rtfHeader); err != nil {
+		return err
+	}
+	var err error
+	d.Each(func(b *Brief, translation string) bool {
+		var rtf string
+		rtf, err = ploverToRTF(translation)
+		if err != nil {
+			err = fmt.Errorf("brief %s: %v", d.system.BriefString(b), err)
+			return false
+		}
+		_, err = fmt.Fprintf(w, "{\\*\\cxs %s}%s\r\n", d.system.BriefString(b), rtf)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "}\r\n")
+	return err
+}
+
+// ploverToRTF converts a single Plover translation into RTF/CRE
+func ploverToRTF(translation string) (string, error) {
+	if strings.HasPrefix(translation, "=") && !strings.ContainsAny(translation, " {}") {
+		return `{\*\cxplovermacro ` + rtfEscape(translation[1:]) + "}", nil
+	}
+	parts, err := ParseTranslation(translation)
+	if err != nil {
+		return "", err
+	}
+	b := new(strings.Builder)
+	for _, p := range parts {
+		if !p.IsOperator {
+			b.WriteString(rtfEscape(p.Text))
+			continue
+		}
+		b.WriteString(ploverOperatorToRTF(p.Text))
+	}
+	return b.String(), nil
+}
+
+func ploverOperatorToRTF(op string) string {
+	switch op {
+	case "^", "^^":
+		return `\cxds `
+	case "-|":
+		return `\cxfc `
+	case ">":
+		return `\cxfl `
+	case ".", ",", "?", "!", ":", ";":
+		return `{\cxp` + op + ` }`
+	case "^\n^":
+		return `\par `
+	case "^\t^":
+		return `\tab `
+	}
+	if strings.HasPrefix(op, "&") {
+		return `{\cxfing ` + rtfEscape(op[1:]) + "}"
+	}
+	if !strings.HasPrefix(op, "#") && !strings.HasPrefix(op, "=") && !strings.HasPrefix(op, ":") && !strings.HasPrefix(op, "*") && strings.Contains(op, "^") {
+		text := op
+		prefix, suffix := "", ""
+		if strings.HasPrefix(text, "^") {
+			prefix = `\cxds `
+			text = text[1:]
+		}
+		if strings.HasSuffix(text, "^") {
+			suffix = `\cxds `
+			text = text[:len(text)-1]
+		}
+		if !strings.Contains(text, "^") {
+			return prefix + rtfEscape(text) + suffix
+		}
+	}
+	return `{\*\cxplovermeta ` + rtfEscape(op) + "}"
+}
+
+// rtfEscape escapes the RTF special characters in the given text, and writes
+// any non-ASCII characters as \u escapes
+func rtfEscape(in string) string {
+	b := new(strings.Builder)
+	for _, r := range in {
+		switch {
+		case r == '\\' || r == '{' || r == '}':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\par `)
+		case r == '\t':
+			b.WriteString(`\tab `)
+		case r < 0x80:
+			b.WriteRune(r)
+		default:
+			for _, u := range utf16.Encode([]rune{r}) {
+				fmt.Fprintf(b, `\u%d?`, int16(u))
+			}
+		}
+	}
+	return b.String()
+}
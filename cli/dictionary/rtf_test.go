@@ -0,0 +1,92 @@
+package dictionary
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseRTF(t *testing.T) {
+	rtf := `{\rtf1\ansi{\*\cxrev100}\cxdict{\*\cxsystem Case CATalyst}{\stylesheet{\s0 Normal;}}
+{\*\cxs TEFT}test
+{\*\cxs -G}\cxds ing
+{\*\cxs RE}re\cxds
+{\*\cxs H-PB}\cxds -\cxds
+{\*\cxs TP-PL}{\cxp. }
+{\*\cxs KP-FPL}\cxfc
+{\*\cxs A*}{\cxfing a}
+{\*\cxs KAFR}caf\u233?
+{\*\cxs R*R}{\*\cxplovermeta #Return}\cxds \cxfl
+{\*\cxs TKPWEUT/HUB}github{\*\cxcomment made up}
+}`
+	d, err := ParseRTF(rtf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		stroke      string
+		translation string
+	}{
+		{"TEFT", "test"},
+		{"-G", "{^ing}"},
+		{"RE", "{re^}"},
+		{"H-PB", "{^-^}"},
+		{"TP-PL", "{.}"},
+		{"KP-FPL", "{-|}"},
+		{"A*", "{&a}"},
+		{"KAFR", "café"},
+		{"R*R", "{#Return}{^}{>}"},
+		{"TKPWEUT/HUB", "github"},
+	}
+	if d.Len() != len(cases) {
+		t.Errorf("expected %d entries, got %d", len(cases), d.Len())
+	}
+	for _, c := range cases {
+		t.Run(c.stroke, func(t *testing.T) {
+			brief, err := ParseBrief(c.stroke)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, ok := d.Lookup(brief)
+			if !ok {
+				t.Fatalf("expected %s to be in the dictionary", c.stroke)
+			}
+			if actual != c.translation {
+				t.Errorf("expected %s to translate to %q, got %q", c.stroke, c.translation, actual)
+			}
+		})
+	}
+}
+
+func TestRTFRoundTrip(t *testing.T) {
+	d := mustDictionary(t, `{
+		"TEFT": "test",
+		"-G": "{^ing}",
+		"RE": "{re^}",
+		"TP-PL": "{.}",
+		"KP-FPL": "{-|}",
+		"A*": "{&a}",
+		"KAFR": "café",
+		"R*R": "{#Return}{^}{>}",
+		"PWRAEUS": "\\{",
+		"P-P": "=undo"
+	}`)
+
+	buf := new(bytes.Buffer)
+	if err := d.WriteRTF(buf); err != nil {
+		t.Fatal(err)
+	}
+	t.Log(buf.String())
+	roundTripped, err := ParseRTF(buf.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff := NewDiff(d, roundTripped)
+	if len(diff.Same) != d.Len() {
+		t.Errorf("expected all %d entries to survive the round trip, got %d", d.Len(), len(diff.Same))
+		for _, e := range diff.Conflicts {
+			t.Errorf("%s: %q became %q", e.Brief, e.A, e.B)
+		}
+	}
+}
@@ -1,15 +1,25 @@
 package dictionary
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"sort"
 	"strings"
 
 	"github.com/apex/log"
 )
 
+// Rules describes the strokes a Factory generates a dictionary from: a layer
+// mask, modifier masks, and the strokes for each key. Version 1 rules files
+// bind the 14 navigation keys and the 4 standard modifiers to the named
+// fields below. Version 2 rules files bind any keys and modifiers, through
+// Keys, Modifiers and Combinations, and declare the factory options too.
 type Rules struct {
+	// Version is the version of the rules file the receiver was read from
+	Version int
+
 	Escape    Keymask
 	Space     Keymask
 	Tab       Keymask
@@ -29,170 +39,284 @@ type Rules struct {
 	Ctrl      Keymask
 	Alt       Keymask
 	Gui       Keymask
+
+	// Keys binds keysyms to strokes. If it is nil, the named key fields
+	// above are used instead.
+	Keys []KeyBinding
+	// Modifiers binds modifier keysyms to strokes. If it is nil, the named
+	// modifier fields above are used instead.
+	Modifiers []ModifierBinding
+	// Combinations lists the combinations of Modifiers to generate. If it is
+	// nil, every combination of two or more modifiers is generated.
+	Combinations []ModifierCombination
+	// Symbols binds symbol keysyms (e.g. bracketleft) to strokes. They are
+	// only generated when the factory option Symbols is set.
+	Symbols []KeyBinding
+	// Templates decide how each generated entry is written
+	Templates Templates
+	// MediaLayer is the mask every media chord is combined with
+	MediaLayer Keymask
+	// Media binds media keysyms to chords. If it is nil, DefaultMediaKeys is
+	// used. They are only generated when the factory option Media is set.
+	Media []KeyBinding
+
+	// Options are the factory options the rules will be generated with. They
+	// decide which number chords MustBeValid checks.
+	Options FactoryOpts
+
+	// system is the steno system the masks belong to. nil means English.
+	system *System
 }
 
-func (r *Rules) MustBeValid() []error {
-	errs := make([]error, 0)
-	keymaskNames := []string{
-		"escape",
-		"space",
-		"tab",
-		"return",
-		"home",
-		"pageUp",
-		"pageDown",
-		"end",
-		"backspace",
-		"delete",
-		"up",
-		"down",
-		"left",
-		"right",
-	}
-	modmaskNames := []string{
-		"layer",
-		"shift",
-		"ctrl",
-		"alt",
-		"gui",
-		"shift-ctrl",
-		"shift-alt",
-		"shift-gui",
-		"ctrl-alt",
-		"alt-gui",
-		"shift-ctrl-alt",
-		"shift-alt-gui",
-		// the below aren't part of Single Stroke Commands
-		"ctrl-gui",
-		"shift-ctrl-gui",
-		"ctrl-alt-gui",
-		"shift-ctrl-alt-gui",
-	}
-	keymasks := []Keymask{
-		r.Escape,
-		r.Space,
-		r.Tab,
-		r.Return,
-		r.Home,
-		r.PageUp,
-		r.PageDown,
-		r.End,
-		r.Backspace,
-		r.Delete,
-		r.Up,
-		r.Down,
-		r.Left,
-		r.Right,
-	}
-	modmasks := []Keymask{
-		r.Layer,
-		r.Shift,
-		r.Ctrl,
-		r.Alt,
-		r.Gui,
-		r.Shift | r.Ctrl,
-		r.Shift | r.Alt,
-		r.Shift | r.Gui,
-		r.Ctrl | r.Alt,
-		r.Alt | r.Gui,
-		r.Shift | r.Ctrl | r.Alt,
-		r.Shift | r.Alt | r.Gui,
-		// the below aren't part of Single Stroke Commands
-		r.Ctrl | r.Gui,
-		r.Shift | r.Ctrl | r.Gui,
-		r.Ctrl | r.Alt | r.Gui,
-		r.Shift | r.Ctrl | r.Alt | r.Gui,
-	}
-	// nothing can be empty
-	for i, m := range keymasks {
-		if m == 0 {
-			errs = append(errs, fmt.Errorf("Mask for %s must not be blank", keymaskNames[i]))
-		}
+const (
+	// RulesV1 is the original rules file format: a flat object of the 14
+	// navigation keys and the layer and modifier masks
+	RulesV1 = 1
+	// RulesV2 is the rules file format with factory options, key bindings
+	// and modifier bindings
+	RulesV2 = 2
+)
+
+// KeyBinding binds a key to the stroke that sends it
+type KeyBinding struct {
+	// Name identifies the binding in validation errors
+	Name   string
+	Keysym QwertyKey
+	Mask   Keymask
+}
+
+// ModifierBinding binds a modifier key to the stroke that holds it down
+type ModifierBinding struct {
+	// Name identifies the binding in validation errors and combinations
+	Name string
+	// Keysym is the X11 name of the modifier, e.g. Shift_L
+	Keysym string
+	Mask   Keymask
+}
+
+// ModifierCombination is a set of modifiers that are held down together
+type ModifierCombination struct {
+	// Modifiers are the names of the combined modifiers, outermost first
+	Modifiers []string
+	// Nonstandard combinations are only generated when the factory option
+	// NonstandardModCombinations is set
+	Nonstandard bool
+}
+
+// sys returns the steno system the receiver's masks belong to
+func (r *Rules) sys() *System {
+	if r.system == nil {
+		return English
 	}
-	for i, m := range modmasks {
-		if m == 0 {
-			errs = append(errs, fmt.Errorf("Mask for %s must not be blank", modmaskNames[i]))
-		}
+	return r.system
+}
+
+// keyChord is a stroke the factory combines with every modifier chord
+type keyChord struct {
+	// name identifies the chord in validation errors, e.g. "escape" or
+	// "numbersRight 6"
+	name string
+	mask Keymask
+	key  QwertyKey
+}
+
+// modifierChord is a stroke the factory combines with every key chord
+type modifierChord struct {
+	// name identifies the chord in validation errors, e.g. "shift-ctrl"
+	name string
+	// modifiers is the same as name, except that it is empty for the layer
+	modifiers   string
+	mask        Keymask
+	mod         QwertyMod
+	nonstandard bool
+}
+
+// keyChords returns the receiver's key bindings, in order
+func (r *Rules) keyChords() []keyChord {
+	keys := r.Keys
+	if keys == nil {
+		keys = r.v1Keys()
 	}
-	// no two key masks can be the same
-	for i, m := range keymasks {
-		for j := i + 1; j < len(keymasks); j++ {
-			if m == keymasks[j] {
-				errs = append(errs, fmt.Errorf("Masks for %s and %s must not be the same (%s)", keymaskNames[i], keymaskNames[j], m))
-			}
-		}
+	chords := make([]keyChord, len(keys))
+	for i, k := range keys {
+		chords[i] = keyChord{k.Name, k.Mask, k.Keysym}
 	}
-	// no two mod masks can be the same
-	for i, m := range modmasks {
-		for j := i + 1; j < len(modmasks); j++ {
-			if m == modmasks[j] {
-				errs = append(errs, fmt.Errorf("Masks for %s and %s must not be the same (%s)", modmaskNames[i], modmaskNames[j], m))
-			}
-		}
+	return chords
+}
+
+// v1Keys returns the receiver's named key fields as key bindings
+func (r *Rules) v1Keys() []KeyBinding {
+	return []KeyBinding{
+		{"escape", Escape, r.Escape},
+		{"space", Space, r.Space},
+		{"tab", Tab, r.Tab},
+		{"return", Return, r.Return},
+		{"home", Home, r.Home},
+		{"pageUp", PageUp, r.PageUp},
+		{"pageDown", PageDown, r.PageDown},
+		{"end", End, r.End},
+		{"backspace", Backspace, r.Backspace},
+		{"delete", Delete, r.Delete},
+		{"up", Up, r.Up},
+		{"down", Down, r.Down},
+		{"left", Left, r.Left},
+		{"right", Right, r.Right},
 	}
-	// no mod+key combination can be the same as another mod
-	for i, m := range modmasks {
-		for j, n := range keymasks {
-			for k, o := range modmasks {
-				if m|n == o {
-					errs = append(errs, fmt.Errorf("Masks for %s+%s and %s must not be the same (%s)", modmaskNames[i], keymaskNames[j], modmaskNames[k], o))
-				}
-			}
-		}
+}
+
+// v1Modifiers returns the receiver's named modifier fields as modifier
+// bindings
+func (r *Rules) v1Modifiers() []ModifierBinding {
+	return []ModifierBinding{
+		{"shift", "Shift_L", r.Shift},
+		{"ctrl", "Control_L", r.Ctrl},
+		{"alt", "Alt_L", r.Alt},
+		{"gui", "Super_L", r.Gui},
 	}
-	// no mod+key combination can be the same as another key
-	for i, m := range modmasks {
-		for j, n := range keymasks {
-			for k, o := range keymasks {
-				if m|n == o {
-					errs = append(errs, fmt.Errorf("Masks for %s+%s and %s must not be the same (%s)", modmaskNames[i], keymaskNames[j], keymaskNames[k], o))
-				}
-			}
-		}
+}
+
+// symbolChords returns the receiver's symbol bindings, if the given options
+// turn the symbol layer on
+func (r *Rules) symbolChords(opts FactoryOpts) []keyChord {
+	if !opts.Symbols {
+		return nil
 	}
-	// no two mod+key combinations can be the same
-	for i, m := range modmasks {
-		for j, n := range keymasks {
-			for k, o := range modmasks {
-				for l, p := range keymasks {
-					if i == k && j == l {
-						continue
-					}
-					if m|n == o|p {
-						errs = append(errs, fmt.Errorf("Masks for %s+%s and %s+%s must not be the same (%s)", modmaskNames[i], keymaskNames[j], modmaskNames[k], keymaskNames[l], Keymask(m|n)))
-					}
-				}
-			}
+	chords := make([]keyChord, len(r.Symbols))
+	for i, k := range r.Symbols {
+		chords[i] = keyChord{k.Name, k.Mask, k.Keysym}
+	}
+	return chords
+}
+
+// fingerspellingChords returns the system's fingerspellings, if the given
+// options turn them on, in alphabetical order
+func (r *Rules) fingerspellingChords(opts FactoryOpts) []keyChord {
+	if !opts.Fingerspellings {
+		return nil
+	}
+	chords := make([]keyChord, 0)
+	for k, q := range r.sys().Fingerspellings() {
+		chords = append(chords, keyChord{string(q), k, q})
+	}
+	sort.Slice(chords, func(i, j int) bool {
+		if chords[i].name != chords[j].name {
+			return chords[i].name < chords[j].name
 		}
+		return chords[i].mask < chords[j].mask
+	})
+	return chords
+}
+
+// v1Combinations are the modifier combinations of version 1 rules. The
+// nonstandard ones aren't part of Single Stroke Commands.
+var v1Combinations = []ModifierCombination{
+	{Modifiers: []string{"shift", "ctrl"}},
+	{Modifiers: []string{"shift", "alt"}},
+	{Modifiers: []string{"shift", "gui"}},
+	{Modifiers: []string{"ctrl", "alt"}},
+	{Modifiers: []string{"alt", "gui"}},
+	{Modifiers: []string{"shift", "ctrl", "alt"}},
+	{Modifiers: []string{"shift", "alt", "gui"}},
+	{Modifiers: []string{"ctrl", "gui"}, Nonstandard: true},
+	{Modifiers: []string{"shift", "ctrl", "gui"}, Nonstandard: true},
+	{Modifiers: []string{"ctrl", "alt", "gui"}, Nonstandard: true},
+	{Modifiers: []string{"shift", "ctrl", "alt", "gui"}, Nonstandard: true},
+}
+
+// modifierChords returns the layer, then each modifier on its own, then
+// each combination of modifiers
+func (r *Rules) modifierChords() []modifierChord {
+	modifiers := r.Modifiers
+	combinations := r.Combinations
+	if modifiers == nil {
+		modifiers = r.v1Modifiers()
+		combinations = v1Combinations
+	} else if combinations == nil {
+		combinations = allCombinations(modifiers)
+	}
+
+	byName := make(map[string]ModifierBinding, len(modifiers))
+	chords := []modifierChord{{name: "layer", mask: r.Layer, mod: "%s"}}
+	for _, m := range modifiers {
+		byName[m.Name] = m
+		chords = append(chords, modifierChord{
+			name:      m.Name,
+			modifiers: m.Name,
+			mask:      m.Mask,
+			mod:       QwertyMod(m.Keysym + "(%s)"),
+		})
 	}
-	// nothing can match a fingerspelling
-	checkedKeymasks := false
-	for i, m := range modmasks {
-		if m.isFingerspelling() {
-			errs = append(errs, fmt.Errorf("Mask for %s matches a fingerspelling (%s)", modmaskNames[i], m))
+	for _, c := range combinations {
+		name := strings.Join(c.Modifiers, "-")
+		chord := modifierChord{
+			name:        name,
+			modifiers:   name,
+			mod:         "%s",
+			nonstandard: c.Nonstandard,
 		}
-		for j, n := range keymasks {
-			if !checkedKeymasks && n.isFingerspelling() {
-				errs = append(errs, fmt.Errorf("Mask for %s matches a fingerspelling (%s)", keymaskNames[j], n))
+		for i := len(c.Modifiers) - 1; i >= 0; i-- {
+			m := byName[c.Modifiers[i]]
+			chord.mask |= m.Mask
+			chord.mod = QwertyMod(m.Keysym + "(" + string(chord.mod) + ")")
+		}
+		chords = append(chords, chord)
+	}
+	return chords
+}
+
+// allCombinations returns every combination of two or more of the given
+// modifiers, smallest first
+func allCombinations(modifiers []ModifierBinding) []ModifierCombination {
+	combinations := make([]ModifierCombination, 0)
+	for size := 2; size <= len(modifiers); size++ {
+		var pick func(start int, names []string)
+		pick = func(start int, names []string) {
+			if len(names) == size {
+				combination := make([]string, size)
+				copy(combination, names)
+				combinations = append(combinations, ModifierCombination{Modifiers: combination})
+				return
 			}
-			if (m | n).isFingerspelling() {
-				errs = append(errs, fmt.Errorf("Mask for %s+%s matches a fingerspelling (%s)", modmaskNames[i], keymaskNames[j], m|n))
+			for i := start; i < len(modifiers); i++ {
+				pick(i+1, append(names, modifiers[i].Name))
 			}
 		}
-		checkedKeymasks = true
+		pick(0, nil)
 	}
-
-	return errs
+	return combinations
 }
 
 func (r *Rules) UnmarshalJSON(b []byte) error {
-	var stringMap map[string]string
-	if err := json.Unmarshal(b, &stringMap); err != nil {
+	var version struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal(b, &version); err != nil {
 		return err
 	}
-	newRules := Rules{}
-	for k, v := range stringMap {
-		stroke, err := ParseStroke(v)
+	if version.Version == nil || *version.Version == RulesV1 {
+		return r.unmarshalV1(b)
+	}
+	if *version.Version == RulesV2 {
+		return r.unmarshalV2(b)
+	}
+	return fmt.Errorf("unsupported rules version %d", *version.Version)
+}
+
+func (r *Rules) unmarshalV1(b []byte) error {
+	var rawMap map[string]json.RawMessage
+	if err := json.Unmarshal(b, &rawMap); err != nil {
+		return err
+	}
+	newRules := Rules{Version: RulesV1, system: r.system}
+	for k, raw := range rawMap {
+		if k == "version" {
+			continue
+		}
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return fmt.Errorf("%s: %v", k, err)
+		}
+		stroke, err := newRules.sys().ParseStroke(v)
 		if err != nil {
 			return err
 		}
@@ -239,20 +363,238 @@ func (r *Rules) UnmarshalJSON(b []byte) error {
 			newRules.Alt = stroke
 		case "gui":
 			newRules.Gui = stroke
+		default:
+			return fmt.Errorf("unknown rules key %q", k)
+		}
+	}
+	*r = newRules
+	return nil
+}
+
+// rulesFileV2 is the on-disk form of version 2 rules
+type rulesFileV2 struct {
+	Version   int          `json:"version"`
+	Options   FactoryOpts  `json:"options"`
+	Layer     string       `json:"layer"`
+	Modifiers []modifierV2 `json:"modifiers"`
+	// Combinations is a pointer so that an empty list can be told apart
+	// from a missing one
+	Combinations *[]combinationV2 `json:"combinations"`
+	Keys         []keyV2          `json:"keys"`
+	Symbols      []keyV2          `json:"symbols,omitempty"`
+	MediaLayer   string           `json:"mediaLayer,omitempty"`
+	Media        []keyV2          `json:"media,omitempty"`
+	Templates    Templates        `json:"templates"`
+}
+
+type modifierV2 struct {
+	// Name defaults to the keysym
+	Name   string `json:"name,omitempty"`
+	Keysym string `json:"keysym"`
+	Stroke string `json:"stroke"`
+}
+
+type combinationV2 struct {
+	Modifiers   []string `json:"modifiers"`
+	Nonstandard bool     `json:"nonstandard"`
+}
+
+type keyV2 struct {
+	// Name defaults to the keysym
+	Name   string `json:"name,omitempty"`
+	Keysym string `json:"keysym"`
+	Stroke string `json:"stroke"`
+}
+
+func (r *Rules) unmarshalV2(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var file rulesFileV2
+	if err := dec.Decode(&file); err != nil {
+		return err
+	}
+
+	newRules := Rules{
+		Version:   RulesV2,
+		Options:   file.Options,
+		Templates: file.Templates,
+		Modifiers: make([]ModifierBinding, 0, len(file.Modifiers)),
+		system:    r.system,
+	}
+	parse := func(what, stroke string) (Keymask, error) {
+		mask, err := newRules.sys().ParseStroke(stroke)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %v", what, err)
+		}
+		return mask, nil
+	}
+	var err error
+	if newRules.Layer, err = parse("layer", file.Layer); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	for _, m := range file.Modifiers {
+		if m.Keysym == "" {
+			return fmt.Errorf("modifier %q has no keysym", m.Name)
+		}
+		if m.Name == "" {
+			m.Name = m.Keysym
+		}
+		if names[m.Name] {
+			return fmt.Errorf("modifier %q is defined more than once", m.Name)
+		}
+		names[m.Name] = true
+		mask, err := parse(m.Name, m.Stroke)
+		if err != nil {
+			return err
+		}
+		newRules.Modifiers = append(newRules.Modifiers, ModifierBinding{Name: m.Name, Keysym: m.Keysym, Mask: mask})
+	}
+	if file.Combinations != nil {
+		newRules.Combinations = make([]ModifierCombination, 0, len(*file.Combinations))
+		for _, c := range *file.Combinations {
+			if len(c.Modifiers) < 2 {
+				return fmt.Errorf("combination %v must have at least two modifiers", c.Modifiers)
+			}
+			for _, name := range c.Modifiers {
+				if !names[name] {
+					return fmt.Errorf("combination %v: unknown modifier %q", c.Modifiers, name)
+				}
+			}
+			newRules.Combinations = append(newRules.Combinations, ModifierCombination{Modifiers: c.Modifiers, Nonstandard: c.Nonstandard})
+		}
+	}
+
+	if newRules.Keys, err = parseKeysV2(file.Keys, parse); err != nil {
+		return err
+	}
+	if newRules.Symbols, err = parseKeysV2(file.Symbols, parse); err != nil {
+		return err
+	}
+	if file.MediaLayer != "" {
+		if newRules.MediaLayer, err = parse("mediaLayer", file.MediaLayer); err != nil {
+			return err
+		}
+	}
+	if file.Media != nil {
+		if newRules.Media, err = parseKeysV2(file.Media, parse); err != nil {
+			return err
 		}
 	}
 	*r = newRules
 	return nil
 }
 
+// MarshalJSON writes the receiver as a version 2 rules file. Version 1 rules
+// are converted, keeping their modifier combinations.
+func (r *Rules) MarshalJSON() ([]byte, error) {
+	system := r.sys()
+	file := rulesFileV2{
+		Version:   RulesV2,
+		Options:   r.Options,
+		Layer:     system.StrokeString(r.Layer),
+		Modifiers: make([]modifierV2, 0),
+		Keys:      writeKeysV2(system, r.Keys),
+		Symbols:   writeKeysV2(system, r.Symbols),
+		Templates: r.Templates,
+	}
+	if r.Keys == nil {
+		file.Keys = writeKeysV2(system, r.v1Keys())
+	}
+	modifiers := r.Modifiers
+	combinations := r.Combinations
+	if modifiers == nil {
+		modifiers = r.v1Modifiers()
+		combinations = v1Combinations
+	}
+	for _, m := range modifiers {
+		file.Modifiers = append(file.Modifiers, modifierV2{Name: m.Name, Keysym: m.Keysym, Stroke: system.StrokeString(m.Mask)})
+	}
+	if combinations != nil {
+		written := make([]combinationV2, len(combinations))
+		for i, c := range combinations {
+			written[i] = combinationV2{Modifiers: c.Modifiers, Nonstandard: c.Nonstandard}
+		}
+		file.Combinations = &written
+	}
+	if r.MediaLayer != 0 {
+		file.MediaLayer = system.StrokeString(r.MediaLayer)
+	}
+	if r.Media != nil {
+		file.Media = writeKeysV2(system, r.Media)
+	}
+
+	// as with dictionaries, json.Marshal would html-escape the > in templates
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(&file); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeKeysV2 is the inverse of parseKeysV2. Names that are the same as the
+// keysym are left out.
+func writeKeysV2(system *System, bindings []KeyBinding) []keyV2 {
+	keys := make([]keyV2, 0, len(bindings))
+	for _, k := range bindings {
+		key := keyV2{Keysym: string(k.Keysym), Stroke: system.StrokeString(k.Mask)}
+		if k.Name != string(k.Keysym) {
+			key.Name = k.Name
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// parseKeysV2 turns version 2 key bindings into KeyBindings. Unknown keysyms
+// are left for MustBeValid to report.
+func parseKeysV2(keys []keyV2, parse func(what, stroke string) (Keymask, error)) ([]KeyBinding, error) {
+	bindings := make([]KeyBinding, 0, len(keys))
+	for _, k := range keys {
+		if k.Keysym == "" {
+			return nil, fmt.Errorf("key %q has no keysym", k.Name)
+		}
+		if k.Name == "" {
+			k.Name = k.Keysym
+		}
+		mask, err := parse(k.Name, k.Stroke)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, KeyBinding{Name: k.Name, Keysym: QwertyKey(k.Keysym), Mask: mask})
+	}
+	return bindings, nil
+}
+
+// ReadRulesFile reads a rules file whose strokes are written for the English
+// system
 func ReadRulesFile(filename string) (*Rules, error) {
+	return ReadSystemRulesFile(filename, English)
+}
+
+// ReadSystemRulesFile reads a rules file whose strokes are written for the
+// given system
+func ReadSystemRulesFile(filename string, s *System) (*Rules, error) {
 	inBytes, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
-	r := Rules{}
+	r := Rules{system: s}
 	if err = json.Unmarshal(inBytes, &r); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %v", filename, err)
 	}
 	return &r, nil
 }
+
+// WriteFile writes the receiver to the given file as a version 2 rules file
+func (r *Rules) WriteFile(filename string) error {
+	b, err := r.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, b, 0644)
+}
@@ -2,6 +2,8 @@ package dictionary
 
 import (
 	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -28,6 +30,7 @@ func TestRulesMustUnmarshal(t *testing.T) {
 		"gui": "-FRLGDZ"
 	}`
 	expected := Rules{
+		Version:   RulesV1,
 		Escape:    LeftS | LeftK | LeftP,
 		Space:     LeftS | LeftP,
 		Tab:       LeftT | LeftP | LeftW,
@@ -57,7 +60,7 @@ func TestRulesMustUnmarshal(t *testing.T) {
 	t.Log(expected)
 	t.Log("actual rules:")
 	t.Log(actual)
-	if expected != actual {
+	if !reflect.DeepEqual(expected, actual) {
 		t.Fatal("Actual rules do not match expected")
 	}
 }
@@ -134,20 +137,6 @@ func TestRulesMustBeValid(t *testing.T) {
 				"Masks for shift-gui+down and shift-alt-gui+down must not be the same (TKPH-FRPLGTSDZ)",
 				"Masks for shift-gui+left and shift-alt-gui+left must not be the same (TPHREFRPLGTSDZ)",
 				"Masks for shift-gui+right and shift-alt-gui+right must not be the same (TREUFRPLGTSDZ)",
-				"Masks for shift-alt-gui+escape and shift-gui+escape must not be the same (SKP-FRPLGTSDZ)",
-				"Masks for shift-alt-gui+space and shift-gui+space must not be the same (SP-FRPLGTSDZ)",
-				"Masks for shift-alt-gui+tab and shift-gui+tab must not be the same (TPW-FRPLGTSDZ)",
-				"Masks for shift-alt-gui+return and shift-gui+return must not be the same (TREFRPLGTSDZ)",
-				"Masks for shift-alt-gui+home and shift-gui+home must not be the same (PWH-FRPLGTSDZ)",
-				"Masks for shift-alt-gui+pageUp and shift-gui+pageUp must not be the same (TKPWUFRPLGTSDZ)",
-				"Masks for shift-alt-gui+pageDown and shift-gui+pageDown must not be the same (TKPWH-FRPLGTSDZ)",
-				"Masks for shift-alt-gui+end and shift-gui+end must not be the same (TKW-FRPLGTSDZ)",
-				"Masks for shift-alt-gui+backspace and shift-gui+backspace must not be the same (KPW-FRPLGTSDZ)",
-				"Masks for shift-alt-gui+delete and shift-gui+delete must not be the same (PWR-FRPLGTSDZ)",
-				"Masks for shift-alt-gui+up and shift-gui+up must not be the same (PUFRPLGTSDZ)",
-				"Masks for shift-alt-gui+down and shift-gui+down must not be the same (TKPH-FRPLGTSDZ)",
-				"Masks for shift-alt-gui+left and shift-gui+left must not be the same (TPHREFRPLGTSDZ)",
-				"Masks for shift-alt-gui+right and shift-gui+right must not be the same (TREUFRPLGTSDZ)",
 				"Masks for ctrl-gui+escape and ctrl-alt-gui+escape must not be the same (SKP*FRLGTSDZ)",
 				"Masks for ctrl-gui+space and ctrl-alt-gui+space must not be the same (SP*FRLGTSDZ)",
 				"Masks for ctrl-gui+tab and ctrl-alt-gui+tab must not be the same (TPW*FRLGTSDZ)",
@@ -176,34 +165,6 @@ func TestRulesMustBeValid(t *testing.T) {
 				"Masks for shift-ctrl-gui+down and shift-ctrl-alt-gui+down must not be the same (TKPH*FRPLGTSDZ)",
 				"Masks for shift-ctrl-gui+left and shift-ctrl-alt-gui+left must not be the same (TPHR*EFRPLGTSDZ)",
 				"Masks for shift-ctrl-gui+right and shift-ctrl-alt-gui+right must not be the same (TR*EUFRPLGTSDZ)",
-				"Masks for ctrl-alt-gui+escape and ctrl-gui+escape must not be the same (SKP*FRLGTSDZ)",
-				"Masks for ctrl-alt-gui+space and ctrl-gui+space must not be the same (SP*FRLGTSDZ)",
-				"Masks for ctrl-alt-gui+tab and ctrl-gui+tab must not be the same (TPW*FRLGTSDZ)",
-				"Masks for ctrl-alt-gui+return and ctrl-gui+return must not be the same (TR*EFRLGTSDZ)",
-				"Masks for ctrl-alt-gui+home and ctrl-gui+home must not be the same (PWH*FRLGTSDZ)",
-				"Masks for ctrl-alt-gui+pageUp and ctrl-gui+pageUp must not be the same (TKPW*UFRLGTSDZ)",
-				"Masks for ctrl-alt-gui+pageDown and ctrl-gui+pageDown must not be the same (TKPWH*FRLGTSDZ)",
-				"Masks for ctrl-alt-gui+end and ctrl-gui+end must not be the same (TKW*FRLGTSDZ)",
-				"Masks for ctrl-alt-gui+backspace and ctrl-gui+backspace must not be the same (KPW*FRLGTSDZ)",
-				"Masks for ctrl-alt-gui+delete and ctrl-gui+delete must not be the same (PWR*FRLGTSDZ)",
-				"Masks for ctrl-alt-gui+up and ctrl-gui+up must not be the same (P*UFRLGTSDZ)",
-				"Masks for ctrl-alt-gui+down and ctrl-gui+down must not be the same (TKPH*FRLGTSDZ)",
-				"Masks for ctrl-alt-gui+left and ctrl-gui+left must not be the same (TPHR*EFRLGTSDZ)",
-				"Masks for ctrl-alt-gui+right and ctrl-gui+right must not be the same (TR*EUFRLGTSDZ)",
-				"Masks for shift-ctrl-alt-gui+escape and shift-ctrl-gui+escape must not be the same (SKP*FRPLGTSDZ)",
-				"Masks for shift-ctrl-alt-gui+space and shift-ctrl-gui+space must not be the same (SP*FRPLGTSDZ)",
-				"Masks for shift-ctrl-alt-gui+tab and shift-ctrl-gui+tab must not be the same (TPW*FRPLGTSDZ)",
-				"Masks for shift-ctrl-alt-gui+return and shift-ctrl-gui+return must not be the same (TR*EFRPLGTSDZ)",
-				"Masks for shift-ctrl-alt-gui+home and shift-ctrl-gui+home must not be the same (PWH*FRPLGTSDZ)",
-				"Masks for shift-ctrl-alt-gui+pageUp and shift-ctrl-gui+pageUp must not be the same (TKPW*UFRPLGTSDZ)",
-				"Masks for shift-ctrl-alt-gui+pageDown and shift-ctrl-gui+pageDown must not be the same (TKPWH*FRPLGTSDZ)",
-				"Masks for shift-ctrl-alt-gui+end and shift-ctrl-gui+end must not be the same (TKW*FRPLGTSDZ)",
-				"Masks for shift-ctrl-alt-gui+backspace and shift-ctrl-gui+backspace must not be the same (KPW*FRPLGTSDZ)",
-				"Masks for shift-ctrl-alt-gui+delete and shift-ctrl-gui+delete must not be the same (PWR*FRPLGTSDZ)",
-				"Masks for shift-ctrl-alt-gui+up and shift-ctrl-gui+up must not be the same (P*UFRPLGTSDZ)",
-				"Masks for shift-ctrl-alt-gui+down and shift-ctrl-gui+down must not be the same (TKPH*FRPLGTSDZ)",
-				"Masks for shift-ctrl-alt-gui+left and shift-ctrl-gui+left must not be the same (TPHR*EFRPLGTSDZ)",
-				"Masks for shift-ctrl-alt-gui+right and shift-ctrl-gui+right must not be the same (TR*EUFRPLGTSDZ)",
 			},
 		},
 		{
@@ -313,3 +274,329 @@ func TestRulesMustBeValid(t *testing.T) {
 		})
 	}
 }
+
+func TestRulesMustBeValidNumbersRight(t *testing.T) {
+	r := &Rules{}
+	if err := json.Unmarshal([]byte(`{
+		"escape": "SKP",
+		"space": "SP",
+		"tab": "TPW",
+		"return": "TRE",
+		"home": "PWH",
+		"pageUp": "TKPWU",
+		"pageDown": "TKPWH",
+		"end": "TKW",
+		"backspace": "KPW",
+		"delete": "PWR",
+		"up": "PU",
+		"down": "TKPH",
+		"left": "TPHRE",
+		"right": "TREU",
+		"layer": "-FRLG",
+		"shift": "-FRPLG",
+		"ctrl": "-FRLGTS",
+		"alt": "-FRBLG",
+		"gui": "-FRLGDZ"
+	}`), r); err != nil {
+		t.Fatal(err)
+	}
+	r.Options = FactoryOpts{NumbersLeft: NumberOptionNumbers, NumberStarsLeft: NumberOptionFunctions}
+	if errs := r.MustBeValid(); len(errs) != 0 {
+		t.Fatalf("expected left-hand numbers to be valid, got %v", errs)
+	}
+
+	// -P is part of shift, so 7 with layer is the same stroke as 6 with shift
+	r.Options.NumbersRight = NumberOptionNumbersHigh
+	expected := "Masks for layer+numbersRight 7 and shift+numbersRight 6 must not be the same (-6R78G)"
+	found := false
+	for _, err := range r.MustBeValid() {
+		if err.Error() == expected {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected error %q, got %v", expected, r.MustBeValid())
+	}
+}
+
+func TestRulesUnmarshalRejectsUnknownKeys(t *testing.T) {
+	cases := map[string]string{
+		"v1 unknown key":        `{"escape": "SKP", "hyper": "-FRLGTSDZ"}`,
+		"v1 non-string":         `{"escape": 1}`,
+		"v2 unknown key":        `{"version": 2, "layer": "-FRLG", "modifierz": []}`,
+		"v2 unknown option":     `{"version": 2, "layer": "-FRLG", "options": {"numbersMiddle": "numbers"}}`,
+		"v2 unknown number":     `{"version": 2, "layer": "-FRLG", "options": {"numbersLeft": "roman"}}`,
+		"v2 unknown binding":    `{"version": 2, "layer": "-FRLG", "keys": [{"keysym": "Escape", "stroke": "SKP", "chord": "SKP"}]}`,
+		"v2 unknown modifier":   `{"version": 2, "layer": "-FRLG", "modifiers": [{"keysym": "Shift_L", "stroke": "-FRPLG"}], "combinations": [{"modifiers": ["Shift_L", "Meta_L"]}]}`,
+		"v2 duplicate modifier": `{"version": 2, "layer": "-FRLG", "modifiers": [{"keysym": "Shift_L", "stroke": "-FRPLG"}, {"keysym": "Shift_L", "stroke": "-FRBLG"}]}`,
+		"unsupported version":   `{"version": 3}`,
+	}
+	for name, in := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &Rules{}
+			if err := json.Unmarshal([]byte(in), r); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestRulesV2(t *testing.T) {
+	r := &Rules{}
+	if err := json.Unmarshal([]byte(`{
+		"version": 2,
+		"options": {"numbersLeft": "functionsHigh"},
+		"layer": "-FRLG",
+		"modifiers": [
+			{"name": "shift", "keysym": "Shift_L", "stroke": "-FRPLG"},
+			{"keysym": "Control_L", "stroke": "*FRLG"},
+			{"name": "hyper", "keysym": "Hyper_L", "stroke": "-FRLGTS"}
+		],
+		"keys": [
+			{"keysym": "Escape", "stroke": "SKP"},
+			{"name": "bright", "keysym": "XF86MonBrightnessUp", "stroke": "PWRAOEU"}
+		]
+	}`), r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Version != RulesV2 || r.Options.NumbersLeft != NumberOptionFunctionsHigh {
+		t.Errorf("expected version 2 with left-hand function keys, got %d and %s", r.Version, r.Options.NumbersLeft)
+	}
+	if len(r.Keys) != 2 || r.Keys[0].Name != "Escape" || r.Keys[1].Keysym != "XF86MonBrightnessUp" {
+		t.Errorf("unexpected keys %v", r.Keys)
+	}
+	if len(r.Modifiers) != 3 || r.Modifiers[1].Name != "Control_L" {
+		t.Errorf("unexpected modifiers %v", r.Modifiers)
+	}
+	if errs := r.MustBeValid(); len(errs) != 0 {
+		t.Fatalf("expected rules to be valid, got %v", errs)
+	}
+
+	d := mustGenerate(t, r.Options, r)
+	cases := map[string]string{
+		"SKP-FRLG":       "{#Escape}{^}{>}",
+		"PWRAOEUFRLG":    "{#XF86MonBrightnessUp}{^}{>}",
+		"SKP*FRPLG":      "{#Shift_L(Control_L(Escape))}{^}{>}",
+		"SKP*FRPLGTS":    "{#Shift_L(Control_L(Hyper_L(Escape)))}{^}{>}",
+		"1-FRLG":         "{#F6}{^}{>}",
+		"PWRAO*EUFRLGTS": "{#Control_L(Hyper_L(XF86MonBrightnessUp))}{^}{>}",
+	}
+	for stroke, expected := range cases {
+		b, err := ParseBrief(stroke)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual, _ := d.Lookup(b); actual != expected {
+			t.Errorf("expected %s to be %q, got %q", stroke, expected, actual)
+		}
+	}
+}
+
+func TestRulesV1AndV2Generate(t *testing.T) {
+	v1, err := ReadRulesFile("../../dictionaries/generator-rules.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := ReadRulesFile("../../dictionaries/generator-rules-v2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.Options != DefaultFactoryOpts {
+		t.Errorf("expected the v2 rules to declare the default options, got %+v", v2.Options)
+	}
+	a := mustGenerate(t, DefaultFactoryOpts, v1)
+	b := mustGenerate(t, v2.Options, v2)
+	diff := NewDiff(a, b)
+	if len(diff.OnlyInA)+len(diff.OnlyInB)+len(diff.Conflicts) != 0 {
+		t.Errorf("expected v1 and v2 rules to generate the same dictionary, got %+v", diff)
+	}
+}
+
+func TestRulesSymbols(t *testing.T) {
+	r := &Rules{}
+	if err := json.Unmarshal([]byte(`{
+		"version": 2,
+		"options": {"symbols": true},
+		"layer": "-FRLG",
+		"modifiers": [{"name": "ctrl", "keysym": "Control_L", "stroke": "*FRLG"}],
+		"keys": [{"keysym": "Escape", "stroke": "SKP"}],
+		"symbols": [
+			{"keysym": "bracketleft", "stroke": "PWR"},
+			{"keysym": "slash", "stroke": "SKP"},
+			{"keysym": "bracket", "stroke": "TKPWR"}
+		]
+	}`), r); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"Masks for Escape and slash must not be the same (SKP)",
+		"Masks for layer+Escape and layer+slash must not be the same (SKP-FRLG)",
+		"Masks for ctrl+Escape and ctrl+slash must not be the same (SKP*FRLG)",
+		"Keysym bracket for bracket is not a valid X11 keysym",
+	}
+	errs := r.MustBeValid()
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), errs)
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("expected error %q, got %q", expected[i], err)
+		}
+	}
+
+	r.Symbols = r.Symbols[:1]
+	if errs := r.MustBeValid(); len(errs) != 0 {
+		t.Fatalf("expected rules to be valid, got %v", errs)
+	}
+	d := mustGenerate(t, r.Options, r)
+	if translation, _ := d.Lookup(SingleStrokeBrief(LeftP | LeftW | LeftR | Star | RightF | RightR | RightL | RightG)); translation != "{#Control_L(bracketleft)}{^}{>}" {
+		t.Errorf("expected ctrl-bracketleft, got %q", translation)
+	}
+	d = mustGenerate(t, FactoryOpts{}, r)
+	if _, ok := d.Lookup(SingleStrokeBrief(LeftP | LeftW | LeftR | RightF | RightR | RightL | RightG)); ok {
+		t.Errorf("expected no symbols without the symbols option")
+	}
+}
+
+func TestRulesMedia(t *testing.T) {
+	r, err := ReadRulesFile("../../dictionaries/generator-rules-v2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Options.Media = true
+	if errs := r.MustBeValid(); len(errs) == 0 || errs[0].Error() != "Mask for mediaLayer must not be blank" {
+		t.Errorf("expected a blank media layer to be an error, got %v", errs)
+	}
+
+	// with -FRLGT as the media layer, -S would be the same as ctrl
+	r.MediaLayer = RightF | RightR | RightL | RightG | RightT
+	r.Media = []KeyBinding{{"stop", "XF86AudioStop", RightS}}
+	expected := "Masks for media+stop and ctrl must not be the same (-FRLGTS)"
+	found := false
+	for _, err := range r.MustBeValid() {
+		if err.Error() == expected {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected error %q, got %v", expected, r.MustBeValid())
+	}
+
+	r.MediaLayer = RightF | RightR | RightL | RightG | RightS
+	r.Media = nil
+	if errs := r.MustBeValid(); len(errs) != 0 {
+		t.Fatalf("expected the default media keys to be valid, got %v", errs)
+	}
+	d := mustGenerate(t, r.Options, r)
+	cases := map[string]string{
+		"P-FRLGS":  "{#XF86AudioPlay}{^}{>}",
+		"R-FRLGS":  "{#XF86AudioRaiseVolume}{^}{>}",
+		"RAFRLGS":  "{#XF86MonBrightnessUp}{^}{>}",
+		"WAFRLGS":  "{#XF86AudioMicMute}{^}{>}",
+		"P*FRLGTS": "",
+	}
+	for stroke, expected := range cases {
+		b, err := ParseBrief(stroke)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual, _ := d.Lookup(b); actual != expected {
+			t.Errorf("expected %s to be %q, got %q", stroke, expected, actual)
+		}
+	}
+}
+
+func TestDefaultMediaKeys(t *testing.T) {
+	s, err := NewSystem(SystemDefinition{
+		Name:               "No vowels",
+		Keys:               []string{"#", "S-", "T-", "K-", "P-", "W-", "H-", "R-", "*", "-F", "-R"},
+		ImplicitHyphenKeys: []string{"*"},
+		NumberKey:          "#",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	media := DefaultMediaKeys(s)
+	if len(media) != 7 {
+		t.Errorf("expected the chords that need A- to be left out, got %v", media)
+	}
+	play, _ := s.Key("P-")
+	if media[2].Name != "play" || media[2].Mask != play {
+		t.Errorf("expected play to be on the system's P- key, got %v", media[2])
+	}
+}
+
+func TestRulesTemplates(t *testing.T) {
+	r, err := ReadRulesFile("../../dictionaries/generator-rules-v2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Templates = Templates{
+		Default: "{#$combo}",
+		Keys:    map[string]string{"Return": "{#$combo}{^}{-|}"},
+	}
+	if errs := r.MustBeValid(); len(errs) != 0 {
+		t.Fatalf("expected the templates to be valid, got %v", errs)
+	}
+	d := mustGenerate(t, r.Options, r)
+	cases := map[string]string{
+		"TRE-FRLG":     "{#Return}{^}{-|}",
+		"TRE-FRPLG":    "{#Shift_L(Return)}{^}{-|}",
+		"SKP-FRLG":     "{#Escape}",
+		"SKP-FRLGTS":   "{#Control_L(Escape)}",
+		"STKPWHR-FRLG": "",
+	}
+	for stroke, expected := range cases {
+		b, err := ParseBrief(stroke)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual, _ := d.Lookup(b); actual != expected {
+			t.Errorf("expected %s to be %q, got %q", stroke, expected, actual)
+		}
+	}
+
+	r.Templates = Templates{
+		Default: "{#$combo}{^",
+		Keys: map[string]string{
+			"Escape":  "{#$combo}{$mods}",
+			"Tab":     "{#$combo Tabb}",
+			"Nothing": "{#$combo}",
+		},
+	}
+	expected := []string{
+		`Default template is invalid for Space: translation "{#Shift_L(Control_L(Alt_L(Super_L(Space))))}{^" has an unclosed { at position 44`,
+		`Template for Escape is invalid: template "{#$combo}{$mods}" has unknown variables $mods`,
+		`Template for Nothing does not match any key`,
+		`Template for Tab is invalid: translation "{#Shift_L(Control_L(Alt_L(Super_L(Tab)))) Tabb}": key combo "Shift_L(Control_L(Alt_L(Super_L(Tab)))) Tabb" has an unknown key "Tabb"`,
+	}
+	errs := r.MustBeValid()
+	actual := make([]string, len(errs))
+	for i, err := range errs {
+		actual[i] = err.Error()
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected errors\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(actual, "\n"))
+	}
+
+	// number chords are named with a space, which is fine in a combo for
+	// the navigation keys but not for them
+	r.Templates = Templates{Default: "{#$key}"}
+	errs = r.MustBeValid()
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "Default template is invalid for numbersLeft 1: ") {
+		t.Errorf("expected the default template to be checked against the number keys, got %v", errs)
+	}
+	if _, err := NewFactory(r.Options).Generate(r); err != nil {
+		t.Errorf("expected a template that expands to be generated, got %v", err)
+	}
+	r.Templates = Templates{Default: "{#$combo}{$mods}"}
+	_, err = NewFactory(r.Options).Generate(r)
+	if err == nil {
+		t.Fatalf("expected a template that can't be expanded to be an error")
+	}
+	for i := 0; i < 10; i++ {
+		if _, again := NewFactory(r.Options).Generate(r); again == nil || again.Error() != err.Error() {
+			t.Fatalf("expected the same error every time, got %v and %v", err, again)
+		}
+	}
+}
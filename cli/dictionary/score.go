@@ -0,0 +1,272 @@
+package dictionary
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/bits"
+)
+
+// ScoreWeights weigh each part of a stroke's Ergonomics to give its score.
+// Lower scores are easier to write.
+type ScoreWeights struct {
+	// Keys is added for every key pressed
+	Keys float64 `json:"keys"`
+	// Stretch is added for every stretch (see Ergonomics)
+	Stretch float64 `json:"stretch"`
+	// SameFinger is added for every finger that presses more than one key.
+	// It is negative by default, since two keys under one finger are easier
+	// than two keys under two fingers.
+	SameFinger float64 `json:"sameFinger"`
+	// Split is added if the stroke uses the fingers of both hands, rather
+	// than being banked on one
+	Split float64 `json:"split"`
+	// KeyCosts are added for particular keys, by name (e.g. "-Z")
+	KeyCosts map[string]float64 `json:"keyCosts,omitempty"`
+}
+
+// DefaultScoreWeights are the weights used unless a config file says
+// otherwise
+var DefaultScoreWeights = ScoreWeights{
+	Keys:       1,
+	Stretch:    1.5,
+	SameFinger: -0.5,
+	Split:      1,
+}
+
+// Ergonomics breaks down how hard a stroke is to write
+type Ergonomics struct {
+	// Keys is the number of keys pressed
+	Keys int `json:"keys"`
+	// Stretches counts adjacent fingers that press opposite rows, reaches to
+	// the number bar or the outer pinky column, and the star when both index
+	// fingers are already busy
+	Stretches int `json:"stretches"`
+	// SameFinger is the number of fingers that press more than one key
+	SameFinger int `json:"sameFinger"`
+	// Split is true if the fingers of both hands are used
+	Split bool `json:"split"`
+}
+
+// ReadScoreWeightsFile reads score weights from a JSON file. Weights the file
+// leaves out keep their default values.
+func ReadScoreWeightsFile(filename string) (ScoreWeights, error) {
+	weights := DefaultScoreWeights
+	inBytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return weights, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(inBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&weights); err != nil {
+		return weights, fmt.Errorf("%s: %v", filename, err)
+	}
+	return weights, nil
+}
+
+// The rows a finger can press. Tall keys (like S- and *) are on both.
+const (
+	rowTop = 1 << iota
+	rowBottom
+	rowBoth = rowTop | rowBottom
+)
+
+// Hands and fingers. Each hand's fingers are numbered from the pinky
+// (fingerPinky) to the index finger (fingerIndex); thumbs are only counted as
+// keys.
+const (
+	handLeft = iota
+	handRight
+	handNone
+
+	fingerPinky = 0
+	fingerIndex = 3
+	fingerThumb = 4
+)
+
+// fingerPosition is where a key sits under the hands
+type fingerPosition struct {
+	hand   int
+	finger int
+	row    int
+	// reach is true for keys a finger has to leave its home to press: the
+	// number bar and the outer pinky column
+	reach bool
+	// shared is true for the star, which either index finger can press
+	shared bool
+}
+
+// stenotypeLayout places the keys of the English Stenotype system. Other
+// systems are scored by the keys they share with it, and by their key
+// costs and number of keys.
+var stenotypeLayout = map[string]fingerPosition{
+	"#":  {handNone, 0, rowTop, true, false},
+	"S-": {handLeft, fingerPinky, rowBoth, false, false},
+	"T-": {handLeft, 1, rowTop, false, false},
+	"K-": {handLeft, 1, rowBottom, false, false},
+	"P-": {handLeft, 2, rowTop, false, false},
+	"W-": {handLeft, 2, rowBottom, false, false},
+	"H-": {handLeft, fingerIndex, rowTop, false, false},
+	"R-": {handLeft, fingerIndex, rowBottom, false, false},
+	"A-": {handLeft, fingerThumb, rowBottom, false, false},
+	"O-": {handLeft, fingerThumb, rowBottom, false, false},
+	"*":  {handNone, fingerIndex, rowBoth, false, true},
+	"-E": {handRight, fingerThumb, rowBottom, false, false},
+	"-U": {handRight, fingerThumb, rowBottom, false, false},
+	"-F": {handRight, fingerIndex, rowTop, false, false},
+	"-R": {handRight, fingerIndex, rowBottom, false, false},
+	"-P": {handRight, 2, rowTop, false, false},
+	"-B": {handRight, 2, rowBottom, false, false},
+	"-L": {handRight, 1, rowTop, false, false},
+	"-G": {handRight, 1, rowBottom, false, false},
+	"-T": {handRight, fingerPinky, rowTop, false, false},
+	"-S": {handRight, fingerPinky, rowBottom, false, false},
+	"-D": {handRight, fingerPinky, rowTop, true, false},
+	"-Z": {handRight, fingerPinky, rowBottom, true, false},
+}
+
+// Scorer rates strokes of a steno system by how hard they are to write
+type Scorer struct {
+	system  *System
+	weights ScoreWeights
+	// positions and costs are indexed by key, in steno order
+	positions []*fingerPosition
+	costs     []float64
+}
+
+// NewScorer returns a scorer for the given system that uses the given
+// weights. It is an error for a key cost to name a key the system doesn't
+// have.
+func NewScorer(s *System, weights ScoreWeights) (*Scorer, error) {
+	keys := s.Keys()
+	sc := &Scorer{
+		system:    s,
+		weights:   weights,
+		positions: make([]*fingerPosition, len(keys)),
+		costs:     make([]float64, len(keys)),
+	}
+	for i, name := range keys {
+		if position, ok := stenotypeLayout[name]; ok {
+			sc.positions[i] = &position
+		}
+	}
+	for name, cost := range weights.KeyCosts {
+		k, err := s.Key(name)
+		if err != nil {
+			return nil, fmt.Errorf("key costs: %v", err)
+		}
+		sc.costs[s.index(k)] = cost
+	}
+	return sc, nil
+}
+
+// englishScorer scores English strokes with the default weights
+var englishScorer, _ = NewScorer(English, DefaultScoreWeights)
+
+// Ergonomics returns the receiver's breakdown of the given stroke
+func (sc *Scorer) Ergonomics(k Keymask) Ergonomics {
+	e := Ergonomics{Keys: bits.OnesCount32(uint32(k))}
+	// rows and presses are indexed by hand and finger
+	var rows [2][fingerThumb]int
+	var presses [2][fingerThumb]int
+	star := false
+	for i, position := range sc.positions {
+		if position == nil || k&sc.system.bit(i) == 0 {
+			continue
+		}
+		if position.reach {
+			e.Stretches++
+		}
+		if position.shared {
+			star = true
+			continue
+		}
+		if position.hand == handNone || position.finger == fingerThumb {
+			continue
+		}
+		rows[position.hand][position.finger] |= position.row
+		presses[position.hand][position.finger]++
+	}
+
+	used := [2]bool{}
+	for hand := range rows {
+		for finger := range rows[hand] {
+			if presses[hand][finger] > 0 {
+				used[hand] = true
+			}
+			if presses[hand][finger] > 1 {
+				e.SameFinger++
+			}
+			if finger == fingerIndex {
+				continue
+			}
+			a, b := rows[hand][finger], rows[hand][finger+1]
+			if (a == rowTop && b == rowBottom) || (a == rowBottom && b == rowTop) {
+				e.Stretches++
+			}
+		}
+	}
+	if star && presses[handLeft][fingerIndex] > 0 && presses[handRight][fingerIndex] > 0 {
+		e.Stretches++
+	}
+	e.Split = used[handLeft] && used[handRight]
+	return e
+}
+
+// Score returns the receiver's score for the given stroke. Lower scores are
+// easier to write.
+func (sc *Scorer) Score(k Keymask) float64 {
+	e := sc.Ergonomics(k)
+	score := float64(e.Keys)*sc.weights.Keys +
+		float64(e.Stretches)*sc.weights.Stretch +
+		float64(e.SameFinger)*sc.weights.SameFinger
+	if e.Split {
+		score += sc.weights.Split
+	}
+	for i, cost := range sc.costs {
+		if k&sc.system.bit(i) != 0 {
+			score += cost
+		}
+	}
+	return score
+}
+
+// BriefScore returns the sum of the scores of the given brief's strokes
+func (sc *Scorer) BriefScore(b *Brief) float64 {
+	score := 0.0
+	for _, stroke := range b.strokes {
+		score += sc.Score(stroke)
+	}
+	return score
+}
+
+// Ergonomics returns the breakdown of the receiver as an English stroke
+func (k Keymask) Ergonomics() Ergonomics {
+	return englishScorer.Ergonomics(k)
+}
+
+// Score returns the score of the receiver as an English stroke, with the
+// default weights. Lower scores are easier to write.
+func (k Keymask) Score() float64 {
+	return englishScorer.Score(k)
+}
+
+// ChordScore is the score of one of the strokes a set of rules generates
+type ChordScore struct {
+	Name   string  `json:"name"`
+	Stroke string  `json:"stroke"`
+	Score  float64 `json:"score"`
+}
+
+// LayerScores returns the score of each of the receiver's keys and symbols
+// on the layer, in order: the strokes that send them without any modifier.
+func (r *Rules) LayerScores(sc *Scorer) []ChordScore {
+	chords := append(r.keyChords(), r.symbolChords(r.Options)...)
+	scores := make([]ChordScore, len(chords))
+	for i, chord := range chords {
+		stroke := r.Layer | chord.mask
+		scores[i] = ChordScore{chord.name, r.sys().StrokeString(stroke), sc.Score(stroke)}
+	}
+	return scores
+}
@@ -0,0 +1,95 @@
+package dictionary
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeymaskScore(t *testing.T) {
+	cases := []struct {
+		stroke     string
+		ergonomics Ergonomics
+		score      float64
+	}{
+		{"S", Ergonomics{Keys: 1}, 1},
+		{"TKPW", Ergonomics{Keys: 4, SameFinger: 2}, 3},
+		{"SKP", Ergonomics{Keys: 3, Stretches: 1}, 4.5},
+		{"-FRLG", Ergonomics{Keys: 4, SameFinger: 2}, 3},
+		{"-FRLGDZ", Ergonomics{Keys: 6, SameFinger: 3, Stretches: 2}, 7.5},
+		{"H*F", Ergonomics{Keys: 3, Stretches: 1, Split: true}, 5.5},
+		{"-Z", Ergonomics{Keys: 1, Stretches: 1}, 2.5},
+	}
+	for _, c := range cases {
+		k, err := ParseStroke(c.stroke)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := k.Ergonomics(); e != c.ergonomics {
+			t.Errorf("expected %s to have ergonomics %+v, got %+v", c.stroke, c.ergonomics, e)
+		}
+		if score := k.Score(); score != c.score {
+			t.Errorf("expected %s to score %v, got %v", c.stroke, c.score, score)
+		}
+	}
+}
+
+func TestReadScoreWeightsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "steno")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "weights.json")
+	if err := ioutil.WriteFile(filename, []byte(`{"stretch": 3, "keyCosts": {"-Z": 2}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	weights, err := ReadScoreWeightsFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weights.Stretch != 3 || weights.Keys != DefaultScoreWeights.Keys {
+		t.Errorf("expected the file to override only the stretch weight, got %+v", weights)
+	}
+	sc, err := NewScorer(English, weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score := sc.Score(RightZ); score != 6 {
+		t.Errorf("expected -Z to score 6 with a key cost, got %v", score)
+	}
+
+	if err := ioutil.WriteFile(filename, []byte(`{"stretches": 3}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadScoreWeightsFile(filename); err == nil {
+		t.Errorf("expected an unknown weight to be an error")
+	}
+	if _, err := NewScorer(English, ScoreWeights{KeyCosts: map[string]float64{"-Q": 1}}); err == nil {
+		t.Errorf("expected a cost for an unknown key to be an error")
+	}
+}
+
+func TestRulesLayerScores(t *testing.T) {
+	r, err := ReadRulesFile("../../dictionaries/generator-rules-v2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores := r.LayerScores(englishScorer)
+	if len(scores) != len(r.Keys)+len(r.Symbols) {
+		t.Fatalf("expected a score for each key and symbol, got %d", len(scores))
+	}
+	for _, s := range scores {
+		k, err := ParseStroke(s.Stroke)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k&r.Layer != r.Layer {
+			t.Errorf("expected %s (%s) to be on the layer", s.Name, s.Stroke)
+		}
+		if s.Score != k.Score() {
+			t.Errorf("expected %s to score %v, got %v", s.Name, k.Score(), s.Score)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package dictionary
+
+import "sort"
+
+// Stack is an ordered list of dictionaries, resolved the way Plover resolves
+// its dictionary list: when more than one dictionary defines a stroke, the
+// one highest in the stack wins.
+type Stack struct {
+	layers []stackLayer
+}
+
+type stackLayer struct {
+	name string
+	dict *Dictionary
+}
+
+// NewStack returns an empty dictionary stack
+func NewStack() *Stack {
+	return &Stack{}
+}
+
+// Push adds a dictionary to the top of the receiver. It takes precedence over
+// every dictionary pushed before it.
+func (s *Stack) Push(name string, d *Dictionary) {
+	s.layers = append(s.layers, stackLayer{name: name, dict: d})
+}
+
+// ReadStackFiles reads each of the given dictionary files and pushes them onto
+// a new stack, in order. The last file is the top of the stack.
+func ReadStackFiles(filenames []string) (*Stack, error) {
+	return ReadSystemStackFiles(filenames, English)
+}
+
+// ReadSystemStackFiles is like ReadStackFiles, but parses the strokes with the
+// given system.
+func ReadSystemStackFiles(filenames []string, system *System) (*Stack, error) {
+	s := NewStack()
+	for _, filename := range filenames {
+		d, err := ReadSystemFile(filename, system)
+		if err != nil {
+			return nil, err
+		}
+		s.Push(filename, d)
+	}
+	return s, nil
+}
+
+// System returns the steno system of the receiver's bottom dictionary, or
+// English if the receiver is empty.
+func (s *Stack) System() *System {
+	if len(s.layers) == 0 {
+		return English
+	}
+	return s.layers[0].dict.system
+}
+
+// Names returns the names of the receiver's dictionaries, from bottom to top.
+func (s *Stack) Names() []string {
+	names := make([]string, len(s.layers))
+	for i, layer := range s.layers {
+		names[i] = layer.name
+	}
+	return names
+}
+
+// Lookup returns the winning translation for the given brief, along with the
+// name of the dictionary it came from.
+func (s *Stack) Lookup(b *Brief) (translation string, source string, ok bool) {
+	for i := len(s.layers) - 1; i >= 0; i-- {
+		if translation, ok := s.layers[i].dict.Lookup(b); ok {
+			return translation, s.layers[i].name, true
+		}
+	}
+	return "", "", false
+}
+
+// ReverseLookup returns every brief whose winning translation is the given
+// string, sorted in steno order. Briefs whose entry is shadowed by a different
+// translation are left out.
+func (s *Stack) ReverseLookup(translation string) []*Brief {
+	seen := make(map[string]bool)
+	briefs := make([]*Brief, 0)
+	for _, layer := range s.layers {
+		for _, b := range layer.dict.ReverseLookup(translation) {
+			if seen[b.key()] {
+				continue
+			}
+			seen[b.key()] = true
+			if winner, _, _ := s.Lookup(b); winner == translation {
+				briefs = append(briefs, b)
+			}
+		}
+	}
+	sortBriefs(briefs)
+	return briefs
+}
+
+// LongestBrief returns the number of strokes in the longest brief of any of
+// the receiver's dictionaries.
+func (s *Stack) LongestBrief() int {
+	longest := 0
+	for _, layer := range s.layers {
+		if l := layer.dict.LongestBrief(); l > longest {
+			longest = l
+		}
+	}
+	return longest
+}
+
+// StackEntry is a single translation of a stroke, and the dictionary that
+// provides it.
+type StackEntry struct {
+	Translation string `json:"translation"`
+	Source      string `json:"source"`
+}
+
+// Resolution is the result of resolving one stroke against a stack: the entry
+// that wins, and the entries it shadows (from highest to lowest).
+type Resolution struct {
+	Brief    *Brief       `json:"-"`
+	Stroke   string       `json:"stroke"`
+	Winner   StackEntry   `json:"winner"`
+	Shadowed []StackEntry `json:"shadowed,omitempty"`
+}
+
+// Resolve returns a resolution for every stroke defined anywhere in the
+// receiver, sorted by stroke.
+func (s *Stack) Resolve() []Resolution {
+	byKey := make(map[string]*Resolution)
+	for i := len(s.layers) - 1; i >= 0; i-- {
+		layer := s.layers[i]
+		layer.dict.Each(func(b *Brief, translation string) bool {
+			entry := StackEntry{Translation: translation, Source: layer.name}
+			if r, ok := byKey[b.key()]; ok {
+				r.Shadowed = append(r.Shadowed, entry)
+				return true
+			}
+			byKey[b.key()] = &Resolution{Brief: b, Stroke: layer.dict.system.BriefString(b), Winner: entry}
+			return true
+		})
+	}
+
+	resolutions := make([]Resolution, 0, len(byKey))
+	for _, r := range byKey {
+		resolutions = append(resolutions, *r)
+	}
+	sort.Slice(resolutions, func(i, j int) bool {
+		return resolutions[i].Stroke < resolutions[j].Stroke
+	})
+	return resolutions
+}
+
+// Flatten returns a single dictionary holding the effective translation of
+// every stroke in the receiver.
+func (s *Stack) Flatten() *Dictionary {
+	d := NewSystemDictionary(s.System())
+	for _, layer := range s.layers {
+		layer.dict.Each(func(b *Brief, translation string) bool {
+			d.Add(b, translation)
+			return true
+		})
+	}
+	return d
+}
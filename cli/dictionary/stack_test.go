@@ -0,0 +1,66 @@
+package dictionary
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func mustDictionary(t *testing.T, in string) *Dictionary {
+	t.Helper()
+	d := NewDictionary()
+	if err := json.Unmarshal([]byte(in), d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestStackResolve(t *testing.T) {
+	s := NewStack()
+	s.Push("main", mustDictionary(t, `{"R-R": "are", "TEFT": "test", "SKP": "and"}`))
+	s.Push("commands", mustDictionary(t, `{"R-R": "{#Return}"}`))
+	s.Push("user", mustDictionary(t, `{"R-R": "{#Escape}", "TKOG": "dog"}`))
+
+	translation, source, ok := s.Lookup(SingleStrokeBrief(LeftR | RightR))
+	if !ok || translation != "{#Escape}" || source != "user" {
+		t.Errorf("expected R-R to resolve to {#Escape} from user, got %s from %s", translation, source)
+	}
+
+	resolutions := s.Resolve()
+	if len(resolutions) != 4 {
+		t.Fatalf("expected 4 resolutions, got %d", len(resolutions))
+	}
+	r := resolutions[0]
+	if r.Stroke != "R-R" {
+		t.Fatalf("expected first resolution to be R-R, got %s", r.Stroke)
+	}
+	if len(r.Shadowed) != 2 {
+		t.Fatalf("expected R-R to shadow 2 entries, got %d", len(r.Shadowed))
+	}
+	if r.Shadowed[0].Source != "commands" || r.Shadowed[1].Source != "main" {
+		t.Errorf("expected shadowed entries from commands then main, got %v", r.Shadowed)
+	}
+
+	flat := s.Flatten()
+	if flat.Len() != 4 {
+		t.Errorf("expected 4 entries in flattened dictionary, got %d", flat.Len())
+	}
+	if translation, _ := flat.Lookup(SingleStrokeBrief(LeftR | RightR)); translation != "{#Escape}" {
+		t.Errorf("expected flattened R-R to be {#Escape}, got %s", translation)
+	}
+}
+
+func TestStackReverseLookup(t *testing.T) {
+	s := NewStack()
+	s.Push("main", mustDictionary(t, `{"R-R": "are", "AR": "are", "TKOG": "dog"}`))
+	s.Push("user", mustDictionary(t, `{"R-R": "{#Return}", "AEU/AR": "are"}`))
+
+	briefs := briefStrings(English, s.ReverseLookup("are"))
+	expected := "AEU/AR AR"
+	if strings.Join(briefs, " ") != expected {
+		t.Errorf("expected the strokes for are to be %s, got %v", expected, briefs)
+	}
+	if briefs := s.ReverseLookup("cat"); len(briefs) != 0 {
+		t.Errorf("expected no strokes for cat, got %v", briefs)
+	}
+}
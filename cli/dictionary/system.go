@@ -0,0 +1,429 @@
+package dictionary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// System describes a steno layout: which keys it has and in what order, which
+// of them stand in for a hyphen, how the number key turns keys into digits,
+// and which strokes are fingerspellings. A Keymask only has meaning alongside
+// the System it was parsed with.
+type System struct {
+	def SystemDefinition
+	// letters holds the character each key is written as, in steno order
+	letters []byte
+	// digits holds the character each key is written as when the number key
+	// is pressed, or 0 if the key has no number
+	digits []byte
+	// firstRight is the index of the first right-hand key
+	firstRight int
+	numberKey  Keymask
+	implicit   Keymask
+	// rights holds every right-hand key that is not an implicit hyphen key
+	rights          Keymask
+	numbers         Keymask
+	fingerspellings map[Keymask]QwertyKey
+	// next is the table ParseStroke scans with. next[cursor][c] describes the
+	// first key at or after cursor that character c stands for: 0 if there is
+	// none, or else the key's index plus one, with nextDigit set if c is the
+	// key's digit rather than its letter.
+	next [][256]uint8
+}
+
+const nextDigit = 0x80
+
+// SystemDefinition is the on-disk form of a System. Keys are named the way
+// Plover names them: left-hand keys end in a hyphen ("S-"), right-hand keys
+// start with one ("-Z"), and keys in the middle have none ("*").
+type SystemDefinition struct {
+	Name string `json:"name"`
+	// Keys lists every key of the system, in steno order. There can be at
+	// most 32 keys.
+	Keys []string `json:"keys"`
+	// ImplicitHyphenKeys are the keys whose presence in a stroke makes the
+	// hyphen unnecessary (usually the vowels and the star)
+	ImplicitHyphenKeys []string `json:"implicitHyphenKeys"`
+	// NumberKey is the key that turns other keys into digits
+	NumberKey string `json:"numberKey"`
+	// Numbers maps keys to the digit they become when the number key is
+	// pressed, e.g. "S-": "1-"
+	Numbers map[string]string `json:"numbers"`
+	// Fingerspellings maps strokes to the letters they fingerspell, without
+	// the star (e.g. "PW": "b")
+	Fingerspellings map[string]string `json:"fingerspellings"`
+}
+
+// English is Plover's 23-key English Stenotype system
+var English = mustSystem(SystemDefinition{
+	Name: "English Stenotype",
+	Keys: []string{
+		"#",
+		"S-", "T-", "K-", "P-", "W-", "H-", "R-",
+		"A-", "O-",
+		"*",
+		"-E", "-U",
+		"-F", "-R", "-P", "-B", "-L", "-G", "-T", "-S", "-D", "-Z",
+	},
+	ImplicitHyphenKeys: []string{"A-", "O-", "*", "-E", "-U"},
+	NumberKey:          "#",
+	Numbers: map[string]string{
+		"S-": "1-",
+		"T-": "2-",
+		"P-": "3-",
+		"H-": "4-",
+		"A-": "5-",
+		"O-": "0-",
+		"-F": "-6",
+		"-P": "-7",
+		"-L": "-8",
+		"-T": "-9",
+	},
+	Fingerspellings: map[string]string{
+		"A":     "a",
+		"PW":    "b",
+		"KR":    "c",
+		"TK":    "d",
+		"E":     "e",
+		"TP":    "f",
+		"TKPW":  "g",
+		"H":     "h",
+		"EU":    "i",
+		"SKWR":  "j",
+		"K":     "k",
+		"HR":    "l",
+		"PH":    "m",
+		"TPH":   "n",
+		"O":     "o",
+		"P":     "p",
+		"KW":    "q",
+		"R":     "r",
+		"S":     "s",
+		"T":     "t",
+		"U":     "u",
+		"SR":    "v",
+		"W":     "w",
+		"KP":    "x",
+		"KWR":   "y",
+		"STKPW": "z",
+		"STK":   "z", // alternate z
+	},
+})
+
+func mustSystem(def SystemDefinition) *System {
+	s, err := NewSystem(def)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// NewSystem builds a System from its definition, and checks that the
+// definition makes sense.
+func NewSystem(def SystemDefinition) (*System, error) {
+	if len(def.Keys) == 0 {
+		return nil, fmt.Errorf("system %s has no keys", def.Name)
+	}
+	if len(def.Keys) > 32 {
+		return nil, fmt.Errorf("system %s has %d keys, but at most 32 are supported", def.Name, len(def.Keys))
+	}
+	s := &System{
+		def:        def,
+		letters:    make([]byte, len(def.Keys)),
+		digits:     make([]byte, len(def.Keys)),
+		firstRight: len(def.Keys),
+	}
+	for i, key := range def.Keys {
+		letter := strings.Trim(key, "-")
+		if len(letter) != 1 {
+			return nil, fmt.Errorf("system %s: key %q must be a single character, with an optional hyphen", def.Name, key)
+		}
+		s.letters[i] = strings.ToUpper(letter)[0]
+		if strings.HasPrefix(key, "-") && len(key) > 1 && i < s.firstRight {
+			s.firstRight = i
+		}
+	}
+
+	var err error
+	if def.NumberKey != "" {
+		if s.numberKey, err = s.keyMask(def.NumberKey); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range def.ImplicitHyphenKeys {
+		mask, err := s.keyMask(key)
+		if err != nil {
+			// implicit hyphen keys may name number keys (e.g. "5-"), which
+			// aren't keys in their own right
+			continue
+		}
+		s.implicit |= mask
+	}
+	for i, key := range def.Keys {
+		if i >= s.firstRight && strings.HasPrefix(key, "-") {
+			s.rights |= s.bit(i)
+		}
+	}
+	s.rights &^= s.implicit
+	for key, number := range def.Numbers {
+		mask, err := s.keyMask(key)
+		if err != nil {
+			return nil, err
+		}
+		digit := strings.Trim(number, "-")
+		if len(digit) != 1 {
+			return nil, fmt.Errorf("system %s: number %q for key %s must be a single character", def.Name, number, key)
+		}
+		s.digits[s.index(mask)] = digit[0]
+		s.numbers |= mask
+	}
+	s.buildNext()
+
+	s.fingerspellings = make(map[Keymask]QwertyKey, len(def.Fingerspellings))
+	for stroke, letter := range def.Fingerspellings {
+		mask, err := s.ParseStroke(stroke)
+		if err != nil {
+			return nil, fmt.Errorf("system %s: fingerspelling %s: %v", def.Name, stroke, err)
+		}
+		s.fingerspellings[mask] = QwertyKey(letter)
+	}
+	return s, nil
+}
+
+// ReadSystemDefinitionFile reads a system definition from a JSON file
+func ReadSystemDefinitionFile(filename string) (*System, error) {
+	inBytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var def SystemDefinition
+	if err = json.Unmarshal(inBytes, &def); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return NewSystem(def)
+}
+
+// buildNext fills in the receiver's parsing table, working back from the last
+// key so that the nearest key always wins.
+func (s *System) buildNext() {
+	s.next = make([][256]uint8, len(s.letters)+1)
+	for i := len(s.letters) - 1; i >= 0; i-- {
+		s.next[i] = s.next[i+1]
+		if d := s.digits[i]; d != 0 {
+			s.next[i][d] = uint8(i+1) | nextDigit
+		}
+		letter := s.letters[i]
+		s.next[i][letter] = uint8(i + 1)
+		if 'A' <= letter && letter <= 'Z' {
+			s.next[i][letter+'a'-'A'] = uint8(i + 1)
+		}
+	}
+}
+
+// Name returns the name of the receiver
+func (s *System) Name() string {
+	return s.def.Name
+}
+
+// Keys returns the names of the receiver's keys, in steno order
+func (s *System) Keys() []string {
+	keys := make([]string, len(s.def.Keys))
+	copy(keys, s.def.Keys)
+	return keys
+}
+
+// bit returns the mask for the key at the given index in steno order. The
+// first key is the most significant bit.
+func (s *System) bit(i int) Keymask {
+	return 1 << uint(len(s.letters)-1-i)
+}
+
+// index is the inverse of bit
+func (s *System) index(k Keymask) int {
+	for i := range s.letters {
+		if s.bit(i) == k {
+			return i
+		}
+	}
+	return -1
+}
+
+// keyMask returns the mask for the key with the given name (e.g. "-T")
+func (s *System) keyMask(name string) (Keymask, error) {
+	for i, key := range s.def.Keys {
+		if key == name {
+			return s.bit(i), nil
+		}
+	}
+	return 0, fmt.Errorf("system %s has no key %q", s.def.Name, name)
+}
+
+// Key returns the mask for the key with the given name (e.g. "-T")
+func (s *System) Key(name string) (Keymask, error) {
+	return s.keyMask(name)
+}
+
+// NumberKey returns the mask of the receiver's number key
+func (s *System) NumberKey() Keymask {
+	return s.numberKey
+}
+
+// Fingerspellings returns the mapping of each of the receiver's
+// fingerspellings to their corresponding Qwerty keys.
+func (s *System) Fingerspellings() map[Keymask]QwertyKey {
+	fingerspellings := make(map[Keymask]QwertyKey, len(s.fingerspellings))
+	for k, q := range s.fingerspellings {
+		fingerspellings[k] = q
+	}
+	return fingerspellings
+}
+
+// IsFingerspelling returns true if the given stroke matches one of the
+// receiver's fingerspellings
+func (s *System) IsFingerspelling(k Keymask) bool {
+	_, ok := s.fingerspellings[k]
+	return ok
+}
+
+// leftNumbers returns the stroke for each number slot on the left hand (see
+// numberSlots), including the number key. Each key takes the slot of its
+// digit.
+func (s *System) leftNumbers() map[byte]Keymask {
+	numbers := make(map[byte]Keymask)
+	for i := 0; i < s.firstRight; i++ {
+		if s.digits[i] != 0 {
+			numbers[s.digits[i]] = s.bit(i) | s.numberKey
+		}
+	}
+	return numbers
+}
+
+// rightNumbers returns the stroke for each number slot on the right hand (see
+// numberSlots), including the number key. The keys whose digits are 6 to 9
+// take slots 1 to 4, and the right-hand vowels take slots 5 and 0, in steno
+// order.
+func (s *System) rightNumbers() map[byte]Keymask {
+	numbers := make(map[byte]Keymask)
+	vowelSlots := []byte{'5', '0'}
+	for i := s.firstRight; i < len(s.letters); i++ {
+		bit := s.bit(i)
+		switch d := s.digits[i]; {
+		case '6' <= d && d <= '9':
+			numbers[d-5] = bit | s.numberKey
+		case bit&s.implicit != 0 && len(vowelSlots) > 0:
+			numbers[vowelSlots[0]] = bit | s.numberKey
+			vowelSlots = vowelSlots[1:]
+		}
+	}
+	return numbers
+}
+
+// star returns the mask of the receiver's "*" key, or 0 if it has none
+func (s *System) star() Keymask {
+	k, _ := s.keyMask("*")
+	return k
+}
+
+// ParseStroke takes in a string (e.g. "STPH") and returns a Keymask or an
+// error. Keys must appear in steno order. A hyphen separates the left hand
+// from the right, and digits imply the number key. It makes a single pass
+// over the input and does not allocate unless the input is invalid.
+func (s *System) ParseStroke(in string) (Keymask, error) {
+	var mask Keymask
+	cursor := 0
+	for i := 0; i < len(in); i++ {
+		c := in[i]
+		if c == '-' {
+			if cursor < s.firstRight {
+				cursor = s.firstRight
+			}
+			continue
+		}
+		found := s.next[cursor][c]
+		if found == 0 {
+			return 0, fmt.Errorf("Input keys %s did not seem to be in steno order (%s)", strings.ToUpper(in), s.order())
+		}
+		j := int(found&^nextDigit) - 1
+		mask |= s.bit(j)
+		if found&nextDigit != 0 {
+			mask |= s.numberKey
+		}
+		cursor = j + 1
+	}
+	return mask, nil
+}
+
+// order returns the receiver's keys in steno order, as a single string
+func (s *System) order() string {
+	str := ""
+	for i := range s.letters {
+		if i == s.firstRight {
+			str += "-"
+		}
+		str += string(s.letters[i])
+	}
+	return str
+}
+
+// StrokeString returns the string representation of the given stroke. If the
+// number key is pressed along with any key that has a number, those keys are
+// written as digits and the number key is left out.
+func (s *System) StrokeString(k Keymask) string {
+	if k == 0 {
+		return ""
+	}
+	useDigits := k&s.numberKey != 0 && k&s.numbers != 0
+	needsHyphen := k&s.rights != 0 && k&s.implicit == 0
+
+	b := make([]byte, 0, len(s.letters)+1)
+	for i := range s.letters {
+		bit := s.bit(i)
+		if i == s.firstRight && needsHyphen {
+			b = append(b, '-')
+		}
+		if k&bit == 0 {
+			continue
+		}
+		switch {
+		case useDigits && bit == s.numberKey:
+		case useDigits && s.digits[i] != 0:
+			b = append(b, s.digits[i])
+		default:
+			b = append(b, s.letters[i])
+		}
+	}
+	return string(b)
+}
+
+// ParseBrief parses a brief of one or more strokes, separated by slashes
+func (s *System) ParseBrief(in string) (*Brief, error) {
+	masks := make([]Keymask, 0, strings.Count(in, separator)+1)
+	rest := in
+	for {
+		stroke := rest
+		end := strings.Index(rest, separator)
+		if end >= 0 {
+			stroke = rest[:end]
+		}
+		mask, err := s.ParseStroke(stroke)
+		if err != nil {
+			return nil, fmt.Errorf("brief %s: %v", in, err)
+		}
+		masks = append(masks, mask)
+		if end < 0 {
+			return &Brief{masks}, nil
+		}
+		rest = rest[end+len(separator):]
+	}
+}
+
+// BriefString returns the string representation of the given brief
+func (s *System) BriefString(b *Brief) string {
+	masks := make([]string, len(b.strokes))
+	for i, stroke := range b.strokes {
+		masks[i] = s.StrokeString(stroke)
+	}
+	return strings.Join(masks, separator)
+}
@@ -0,0 +1,110 @@
+package dictionary
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEnglishSystemFile(t *testing.T) {
+	s, err := ReadSystemDefinitionFile("../../dictionaries/systems/english.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []string{"STKPWHRAO*EUFRPBLGTSDZ", "SHR-FRLG", "12K3W4R50*EU6R7B8G9SDZ", "#-Z", "1-6"}
+	for _, c := range cases {
+		mask, err := s.ParseStroke(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := ParseStroke(c)
+		if mask != expected {
+			t.Errorf("expected %s to parse the same as the built-in English system", c)
+		}
+		if actual := s.StrokeString(mask); actual != c {
+			t.Errorf("expected %s to round trip, got %s", c, actual)
+		}
+	}
+}
+
+func TestExtendedSystem(t *testing.T) {
+	s, err := NewSystem(SystemDefinition{
+		Name: "Extended English",
+		Keys: []string{
+			"#", "^-", "+-",
+			"S-", "T-", "K-", "P-", "W-", "H-", "R-",
+			"A-", "O-",
+			"*",
+			"-E", "-U",
+			"-F", "-R", "-P", "-B", "-L", "-G", "-T", "-S", "-D", "-Z",
+		},
+		ImplicitHyphenKeys: []string{"A-", "O-", "*", "-E", "-U"},
+		NumberKey:          "#",
+		Numbers:            map[string]string{"S-": "1-", "-F": "-6"},
+		Fingerspellings:    map[string]string{"^PW": "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caret, _ := s.Key("^-")
+	plus, _ := s.Key("+-")
+	leftS, _ := s.Key("S-")
+	rightS, _ := s.Key("-S")
+	cases := []struct {
+		in       string
+		expected Keymask
+		out      string
+	}{
+		{"^S", caret | leftS, "^S"},
+		{"+-S", plus | rightS, "+-S"},
+		{"^+SS", caret | plus | leftS | rightS, "^+S-S"},
+		{"1", s.NumberKey() | leftS, "1"},
+	}
+	for _, c := range cases {
+		mask, err := s.ParseStroke(c.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mask != c.expected {
+			t.Errorf("expected %s to parse as %b, got %b", c.in, c.expected, mask)
+		}
+		if actual := s.StrokeString(mask); actual != c.out {
+			t.Errorf("expected %s to be written as %s, got %s", c.in, c.out, actual)
+		}
+	}
+
+	if _, err := s.ParseStroke("S^"); err == nil {
+		t.Errorf("expected S^ to be out of steno order")
+	}
+	fingerspelling, _ := s.ParseStroke("^PW")
+	if !s.IsFingerspelling(fingerspelling) {
+		t.Errorf("expected ^PW to be a fingerspelling")
+	}
+}
+
+func TestPalantypeSystem(t *testing.T) {
+	s, err := ReadSystemDefinitionFile("../../dictionaries/systems/palantype.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.ParseBrief("PA/N-N/TIS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := s.BriefString(b); actual != "PA/N-N/TIS" {
+		t.Errorf("expected PA/N-N/TIS to round trip, got %s", actual)
+	}
+	if _, err := s.ParseStroke("-NN"); err == nil {
+		t.Errorf("expected -NN to be out of steno order")
+	}
+
+	d := NewSystemDictionary(s)
+	d.Add(b, "pantis")
+	out, err := d.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(out)) != "{\n  \"PA/N-N/TIS\": \"pantis\"\n}" {
+		t.Errorf("expected the dictionary to be written in Palantype, got %s", out)
+	}
+}
@@ -0,0 +1,189 @@
+package dictionary
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DefaultTemplate is the template generated entries use unless the rules say
+// otherwise: the key combo, then Plover's attach and lowercase-next operators.
+const DefaultTemplate = "{#$combo}{^}{>}"
+
+// Templates decide how each generated entry is written. A template is a
+// Plover translation in which these variables are replaced:
+//
+//	$combo      the key combo, e.g. Shift_L(Control_L(Return))
+//	$modifiers  the modifier names, e.g. shift-ctrl (empty on the layer)
+//	$key        the key name, e.g. return
+//	$keysym     the key's keysym, e.g. Return
+//
+// Variables may also be written as ${combo}, and $$ is a literal $.
+type Templates struct {
+	// Default is used for every key without an override. If it is empty,
+	// DefaultTemplate is used.
+	Default string `json:"default,omitempty"`
+	// Keys maps key names (as they appear in validation errors) to the
+	// template to use for them
+	Keys map[string]string `json:"keys,omitempty"`
+}
+
+// templateVars are the values substituted into a template
+type templateVars struct {
+	combo     string
+	modifiers string
+	key       string
+	keysym    string
+}
+
+func newTemplateVars(mod modifierChord, key keyChord) templateVars {
+	return templateVars{
+		combo:     string(mod.mod.apply(string(key.key))),
+		modifiers: mod.modifiers,
+		key:       key.name,
+		keysym:    string(key.key),
+	}
+}
+
+// expandTemplate replaces the variables in the given template
+func expandTemplate(template string, vars templateVars) (string, error) {
+	unknown := make([]string, 0)
+	out := os.Expand(template, func(name string) string {
+		switch name {
+		case "combo":
+			return vars.combo
+		case "modifiers":
+			return vars.modifiers
+		case "key":
+			return vars.key
+		case "keysym":
+			return vars.keysym
+		case "$":
+			return "$"
+		}
+		unknown = append(unknown, "$"+name)
+		return ""
+	})
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("template %q has unknown variables %s", template, strings.Join(unknown, ", "))
+	}
+	return out, nil
+}
+
+// template returns the template for the key with the given name
+func (r *Rules) template(key string) string {
+	if template, ok := r.Templates.Keys[key]; ok {
+		return template
+	}
+	if r.Templates.Default != "" {
+		return r.Templates.Default
+	}
+	return DefaultTemplate
+}
+
+// definition returns the translation the factory generates for the given
+// modifier and key
+func (r *Rules) definition(mod modifierChord, key keyChord) (string, error) {
+	definition, err := expandTemplate(r.template(key.name), newTemplateVars(mod, key))
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", key.name, err)
+	}
+	return definition, nil
+}
+
+// templateErrors checks that each of the receiver's templates expands to a
+// well-formed Plover translation for every key it applies to, both with the
+// most deeply nested modifier combo and on the layer alone, and that every
+// override names one of the keys. Media keys are only checked on the layer,
+// since that's the only way the factory generates them. A template that is
+// invalid for more than one key is only reported for the first. Each error is
+// a *ValidationError.
+func (r *Rules) templateErrors(mods []modifierChord, keys, media []keyChord) []error {
+	errs := make([]error, 0)
+	if len(mods) == 0 || len(keys)+len(media) == 0 {
+		return errs
+	}
+	// the first modifier chord is the layer, and the last is the most deeply
+	// nested combo
+	layer, deepest := mods[0], mods[len(mods)-1]
+	type check struct {
+		key  keyChord
+		mods []modifierChord
+	}
+	checks := make([]check, 0, len(keys)+len(media))
+	for _, key := range keys {
+		checks = append(checks, check{key, []modifierChord{deepest, layer}})
+	}
+	for _, key := range media {
+		checks = append(checks, check{key, []modifierChord{{mod: "%s"}}})
+	}
+
+	// invalid holds the first error for the default template and for each
+	// override
+	var invalidDefault error
+	invalid := make(map[string]error)
+	for _, c := range checks {
+		template, overridden := r.Templates.Keys[c.key.name]
+		if !overridden && r.Templates.Default == "" {
+			continue
+		}
+		if (overridden && invalid[c.key.name] != nil) || (!overridden && invalidDefault != nil) {
+			continue
+		}
+		if !overridden {
+			template = r.Templates.Default
+		}
+		for _, mod := range c.mods {
+			err := checkTemplate(template, newTemplateVars(mod, c.key))
+			if err == nil {
+				continue
+			}
+			if overridden {
+				invalid[c.key.name] = err
+			} else {
+				invalidDefault = templateError("default", "Default template is invalid for %s: %v", c.key.name, err)
+			}
+			break
+		}
+	}
+	if invalidDefault != nil {
+		errs = append(errs, invalidDefault)
+	}
+
+	names := make([]string, 0, len(r.Templates.Keys))
+	for name := range r.Templates.Keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	byName := make(map[string]bool, len(checks))
+	for _, c := range checks {
+		byName[c.key.name] = true
+	}
+	for _, name := range names {
+		if !byName[name] {
+			errs = append(errs, templateError(name, "Template for %s does not match any key", name))
+			continue
+		}
+		if err := invalid[name]; err != nil {
+			errs = append(errs, templateError(name, "Template for %s is invalid: %v", name, err))
+		}
+	}
+	return errs
+}
+
+func templateError(name, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{
+		Code:    ValidationTemplate,
+		Names:   []string{name},
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func checkTemplate(template string, vars templateVars) error {
+	out, err := expandTemplate(template, vars)
+	if err != nil {
+		return err
+	}
+	return ValidateTranslation(out)
+}
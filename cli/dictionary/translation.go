@@ -0,0 +1,165 @@
+package dictionary
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TranslationPart is one piece of a Plover translation: either a run of
+// literal text, or a formatting operator (the part between `{` and `}`).
+type TranslationPart struct {
+	// Text is the literal text (with escapes removed) if the part is not an
+	// operator, or the body of the operator (without braces) if it is.
+	Text       string
+	IsOperator bool
+}
+
+func (p TranslationPart) String() string {
+	if p.IsOperator {
+		return "{" + p.Text + "}"
+	}
+	return translationEscaper.Replace(p.Text)
+}
+
+var translationEscaper = strings.NewReplacer("{", `\{`, "}", `\}`)
+
+// ParseTranslation splits a Plover translation (e.g. `{#Return}{^}{-|}`) into
+// its parts. It returns an error if the braces in the translation do not
+// balance.
+func ParseTranslation(in string) ([]TranslationPart, error) {
+	parts := make([]TranslationPart, 0)
+	text := new(strings.Builder)
+	flushText := func() {
+		if text.Len() > 0 {
+			parts = append(parts, TranslationPart{Text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(in); i++ {
+		c := in[i]
+		switch c {
+		case '\\':
+			if i+1 < len(in) && (in[i+1] == '{' || in[i+1] == '}') {
+				text.WriteByte(in[i+1])
+				i++
+				continue
+			}
+			text.WriteByte(c)
+		case '{':
+			end := -1
+			for j := i + 1; j < len(in); j++ {
+				if in[j] == '\\' {
+					j++
+					continue
+				}
+				if in[j] == '{' {
+					return nil, fmt.Errorf("translation %q has a nested { at position %d", in, j)
+				}
+				if in[j] == '}' {
+					end = j
+					break
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("translation %q has an unclosed { at position %d", in, i)
+			}
+			flushText()
+			parts = append(parts, TranslationPart{Text: in[i+1 : end], IsOperator: true})
+			i = end
+		case '}':
+			return nil, fmt.Errorf("translation %q has an unopened } at position %d", in, i)
+		default:
+			text.WriteByte(c)
+		}
+	}
+	flushText()
+	return parts, nil
+}
+
+// JoinTranslation is the inverse of ParseTranslation
+func JoinTranslation(parts []TranslationPart) string {
+	b := new(strings.Builder)
+	for _, p := range parts {
+		b.WriteString(p.String())
+	}
+	return b.String()
+}
+
+// ValidateTranslation checks that a Plover translation is well formed: its
+// braces balance, no operator is empty, and every key combo (e.g.
+// `{#Control_L(bracketleft) Return}`) has balanced parentheses and only names
+// keysyms Plover knows.
+func ValidateTranslation(in string) error {
+	parts, err := ParseTranslation(in)
+	if err != nil {
+		return err
+	}
+	for _, p := range parts {
+		if !p.IsOperator {
+			continue
+		}
+		if strings.TrimSpace(p.Text) == "" {
+			return fmt.Errorf("translation %q has an empty operator", in)
+		}
+		if strings.HasPrefix(p.Text, "#") {
+			if err := validateKeyCombo(p.Text[1:]); err != nil {
+				return fmt.Errorf("translation %q: %v", in, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateKeyCombo checks the body of a key combo operator, e.g.
+// `Shift_L(Control_L(a)) Return`
+func validateKeyCombo(combo string) error {
+	if strings.TrimSpace(combo) == "" {
+		return fmt.Errorf("key combo is empty")
+	}
+	depth := 0
+	name := new(strings.Builder)
+	endName := func() error {
+		if name.Len() == 0 {
+			return nil
+		}
+		if !IsKeysym(name.String()) {
+			return fmt.Errorf("key combo %q has an unknown key %q", combo, name.String())
+		}
+		name.Reset()
+		return nil
+	}
+	for i := 0; i < len(combo); i++ {
+		switch c := combo[i]; c {
+		case '(':
+			if name.Len() == 0 {
+				return fmt.Errorf("key combo %q has a ( without a key at position %d", combo, i)
+			}
+			if err := endName(); err != nil {
+				return err
+			}
+			depth++
+		case ')':
+			if err := endName(); err != nil {
+				return err
+			}
+			if depth == 0 {
+				return fmt.Errorf("key combo %q has an unopened ) at position %d", combo, i)
+			}
+			depth--
+		case ' ':
+			if err := endName(); err != nil {
+				return err
+			}
+		default:
+			name.WriteByte(c)
+		}
+	}
+	if err := endName(); err != nil {
+		return err
+	}
+	if depth != 0 {
+		return fmt.Errorf("key combo %q has an unclosed (", combo)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package dictionary
+
+import "testing"
+
+func TestValidateTranslation(t *testing.T) {
+	valid := []string{
+		"test",
+		"{#Return}{^}{-|}",
+		"{#Shift_L(Control_L(bracketleft)) Return}",
+		"{^ing}",
+		`\{literal\}`,
+	}
+	for _, in := range valid {
+		if err := ValidateTranslation(in); err != nil {
+			t.Errorf("expected %q to be valid, got %v", in, err)
+		}
+	}
+	invalid := []string{
+		"{#Return",
+		"{}",
+		"{#}",
+		"{#Shift_L(Return}",
+		"{#Shift_L(Return))}",
+		"{#(Return)}",
+		"{#Retrun}",
+	}
+	for _, in := range invalid {
+		if err := ValidateTranslation(in); err == nil {
+			t.Errorf("expected %q to be invalid", in)
+		}
+	}
+}
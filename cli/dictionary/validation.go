@@ -0,0 +1,177 @@
+package dictionary
+
+import "fmt"
+
+// ValidationCode identifies the kind of problem a ValidationError describes
+type ValidationCode string
+
+const (
+	// ValidationBlank means a chord has no keys
+	ValidationBlank ValidationCode = "blank"
+	// ValidationDuplicate means two chords generate the same stroke
+	ValidationDuplicate ValidationCode = "duplicate"
+	// ValidationFingerspelling means a chord generates a fingerspelling
+	ValidationFingerspelling ValidationCode = "fingerspelling"
+	// ValidationKeysym means a binding names a keysym Plover doesn't know
+	ValidationKeysym ValidationCode = "keysym"
+	// ValidationTemplate means a template doesn't give a well-formed
+	// translation, or names a key that doesn't exist
+	ValidationTemplate ValidationCode = "template"
+)
+
+// ValidationError is a problem MustBeValid found with a set of rules
+type ValidationError struct {
+	Code ValidationCode `json:"code"`
+	// Names are the chords at fault, as they are named in the rules (e.g.
+	// "escape", "shift-ctrl" or "layer+escape" for the layer combined with
+	// escape)
+	Names []string `json:"names"`
+	// Keymask is the stroke at fault, if there is one
+	Keymask Keymask `json:"keymask,omitempty"`
+	// Stroke is Keymask written in the rules' steno system
+	Stroke  string `json:"stroke,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// validationChord is a stroke the rules generate, with the name of the
+// chord(s) it comes from
+type validationChord struct {
+	name string
+	mask Keymask
+}
+
+// validator builds up the errors of a MustBeValid
+type validator struct {
+	system *System
+	errs   []error
+}
+
+func (v *validator) add(code ValidationCode, names []string, mask Keymask, format string, args ...interface{}) {
+	e := &ValidationError{Code: code, Names: names}
+	if mask != 0 {
+		e.Keymask = mask
+		e.Stroke = v.system.StrokeString(mask)
+		args = append(args, e.Stroke)
+	}
+	e.Message = fmt.Sprintf(format, args...)
+	v.errs = append(v.errs, e)
+}
+
+// blank reports every chord with no keys
+func (v *validator) blank(chords []validationChord) {
+	for _, c := range chords {
+		if c.mask == 0 {
+			v.add(ValidationBlank, []string{c.name}, 0, "Mask for %s must not be blank", c.name)
+		}
+	}
+}
+
+// index maps each stroke in the given chords to the first chord that has it,
+// reporting every later chord with the same stroke
+func (v *validator) index(chords []validationChord) map[Keymask]string {
+	firsts := make(map[Keymask]string, len(chords))
+	for _, c := range chords {
+		if first, ok := firsts[c.mask]; ok {
+			v.add(ValidationDuplicate, []string{first, c.name}, c.mask, "Masks for %s and %s must not be the same (%s)", first, c.name)
+			continue
+		}
+		firsts[c.mask] = c.name
+	}
+	return firsts
+}
+
+// collide reports every chord whose stroke is in the given index
+func (v *validator) collide(chords []validationChord, index map[Keymask]string) {
+	for _, c := range chords {
+		if other, ok := index[c.mask]; ok {
+			v.add(ValidationDuplicate, []string{c.name, other}, c.mask, "Masks for %s and %s must not be the same (%s)", c.name, other)
+		}
+	}
+}
+
+// fingerspellings reports every chord whose stroke is a fingerspelling
+func (v *validator) fingerspellings(chords []validationChord) {
+	for _, c := range chords {
+		if v.system.IsFingerspelling(c.mask) {
+			v.add(ValidationFingerspelling, []string{c.name}, c.mask, "Mask for %s matches a fingerspelling (%s)", c.name)
+		}
+	}
+}
+
+// MustBeValid checks that every stroke the receiver would generate is
+// distinct, and that none of them is a fingerspelling. Each error is a
+// *ValidationError. Strokes are checked by hashing, so the check grows with
+// the number of strokes generated rather than its square.
+func (r *Rules) MustBeValid() []error {
+	system := r.sys()
+	v := &validator{system: system, errs: make([]error, 0)}
+
+	// number chords are generated alongside the keys, so they must not
+	// collide with anything either
+	keyChords := append(r.keyChords(), r.symbolChords(r.Options)...)
+	keys := make([]validationChord, 0)
+	for _, chord := range append(keyChords, numberChords(system, r.Options)...) {
+		keys = append(keys, validationChord{chord.name, chord.mask})
+	}
+	modChords := r.modifierChords()
+	mods := make([]validationChord, 0, len(modChords))
+	combos := make([]validationChord, 0, len(modChords)*len(keys))
+	for _, mod := range modChords {
+		mods = append(mods, validationChord{mod.name, mod.mask})
+		for _, key := range keys {
+			combos = append(combos, validationChord{mod.name + "+" + key.name, mod.mask | key.mask})
+		}
+	}
+
+	// nothing can be empty
+	v.blank(keys)
+	v.blank(mods)
+	// no two key masks, and no two mod masks, can be the same
+	keyIndex := v.index(keys)
+	modIndex := v.index(mods)
+	// no mod+key combination can be the same as another mod, another key, or
+	// another mod+key combination
+	v.collide(combos, modIndex)
+	v.collide(combos, keyIndex)
+	comboIndex := v.index(combos)
+	// nothing can match a fingerspelling
+	v.fingerspellings(mods)
+	v.fingerspellings(keys)
+	v.fingerspellings(combos)
+
+	// media strokes are used as they are, so they must not be blank or match
+	// any other stroke
+	mediaChords := r.mediaChords(r.Options)
+	if len(mediaChords) > 0 && r.MediaLayer == 0 {
+		v.add(ValidationBlank, []string{"mediaLayer"}, 0, "Mask for mediaLayer must not be blank")
+	}
+	media := make([]validationChord, len(mediaChords))
+	for i, chord := range mediaChords {
+		media[i] = validationChord{chord.name, chord.mask}
+	}
+	v.index(media)
+	v.collide(media, modIndex)
+	v.collide(media, comboIndex)
+	v.fingerspellings(media)
+
+	// every keysym must be one Plover knows
+	for _, chord := range append(keyChords, mediaChords...) {
+		if !IsKeysym(string(chord.key)) {
+			v.add(ValidationKeysym, []string{chord.name}, 0, "Keysym %s for %s is not a valid X11 keysym", chord.key, chord.name)
+		}
+	}
+	for _, m := range r.Modifiers {
+		if !IsKeysym(m.Keysym) {
+			v.add(ValidationKeysym, []string{m.Name}, 0, "Keysym %s for %s is not a valid X11 keysym", m.Keysym, m.Name)
+		}
+	}
+	// every template must give well-formed translations
+	allKeyChords := append(append(append([]keyChord{}, keyChords...), numberChords(system, r.Options)...), r.fingerspellingChords(r.Options)...)
+	v.errs = append(v.errs, r.templateErrors(modChords, allKeyChords, mediaChords)...)
+
+	return v.errs
+}
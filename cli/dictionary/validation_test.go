@@ -0,0 +1,87 @@
+package dictionary
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRulesValidationErrors(t *testing.T) {
+	r := &Rules{}
+	if err := json.Unmarshal([]byte(`{
+		"version": 2,
+		"layer": "-FRLG",
+		"modifiers": [
+			{"name": "shift", "keysym": "Shift_L", "stroke": "-FRPLG"},
+			{"name": "ctrl", "keysym": "Control", "stroke": ""}
+		],
+		"keys": [
+			{"keysym": "Escape", "stroke": "SKP"},
+			{"keysym": "Tab", "stroke": "SKP"},
+			{"keysym": "Return", "stroke": "A"}
+		],
+		"templates": {"keys": {"Nothing": "{#$combo}"}}
+	}`), r); err != nil {
+		t.Fatal(err)
+	}
+	skp := LeftS | LeftK | LeftP
+	layer := RightF | RightR | RightL | RightG
+	expected := []*ValidationError{
+		{ValidationBlank, []string{"ctrl"}, 0, "", "Mask for ctrl must not be blank"},
+		{ValidationDuplicate, []string{"Escape", "Tab"}, skp, "SKP", "Masks for Escape and Tab must not be the same (SKP)"},
+		{ValidationDuplicate, []string{"shift", "shift-ctrl"}, layer | RightP, "-FRPLG", "Masks for shift and shift-ctrl must not be the same (-FRPLG)"},
+		{ValidationDuplicate, []string{"ctrl+Escape", "Escape"}, skp, "SKP", "Masks for ctrl+Escape and Escape must not be the same (SKP)"},
+		{ValidationDuplicate, []string{"ctrl+Tab", "Escape"}, skp, "SKP", "Masks for ctrl+Tab and Escape must not be the same (SKP)"},
+		{ValidationDuplicate, []string{"ctrl+Return", "Return"}, LeftA, "A", "Masks for ctrl+Return and Return must not be the same (A)"},
+		{ValidationDuplicate, []string{"layer+Escape", "layer+Tab"}, skp | layer, "SKP-FRLG", "Masks for layer+Escape and layer+Tab must not be the same (SKP-FRLG)"},
+		{ValidationDuplicate, []string{"shift+Escape", "shift+Tab"}, skp | layer | RightP, "SKP-FRPLG", "Masks for shift+Escape and shift+Tab must not be the same (SKP-FRPLG)"},
+		{ValidationDuplicate, []string{"ctrl+Escape", "ctrl+Tab"}, skp, "SKP", "Masks for ctrl+Escape and ctrl+Tab must not be the same (SKP)"},
+		{ValidationDuplicate, []string{"shift+Escape", "shift-ctrl+Escape"}, skp | layer | RightP, "SKP-FRPLG", "Masks for shift+Escape and shift-ctrl+Escape must not be the same (SKP-FRPLG)"},
+		{ValidationDuplicate, []string{"shift+Escape", "shift-ctrl+Tab"}, skp | layer | RightP, "SKP-FRPLG", "Masks for shift+Escape and shift-ctrl+Tab must not be the same (SKP-FRPLG)"},
+		{ValidationDuplicate, []string{"shift+Return", "shift-ctrl+Return"}, LeftA | layer | RightP, "AFRPLG", "Masks for shift+Return and shift-ctrl+Return must not be the same (AFRPLG)"},
+		{ValidationFingerspelling, []string{"Return"}, LeftA, "A", "Mask for Return matches a fingerspelling (A)"},
+		{ValidationFingerspelling, []string{"ctrl+Return"}, LeftA, "A", "Mask for ctrl+Return matches a fingerspelling (A)"},
+		{ValidationKeysym, []string{"ctrl"}, 0, "", "Keysym Control for ctrl is not a valid X11 keysym"},
+		{ValidationTemplate, []string{"Nothing"}, 0, "", "Template for Nothing does not match any key"},
+	}
+	errs := r.MustBeValid()
+	actual := make([]*ValidationError, len(errs))
+	for i, err := range errs {
+		var ok bool
+		if actual[i], ok = err.(*ValidationError); !ok {
+			t.Fatalf("expected a *ValidationError, got %T", err)
+		}
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		for _, e := range actual {
+			t.Logf("%+v", *e)
+		}
+		t.Errorf("expected %d errors, got %d", len(expected), len(actual))
+	}
+
+	out, err := json.Marshal(actual[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"code":"duplicate","names":["Escape","Tab"],"keymask":`+fmt.Sprint(uint32(skp))+`,"stroke":"SKP","message":"Masks for Escape and Tab must not be the same (SKP)"}` {
+		t.Errorf("unexpected JSON %s", out)
+	}
+}
+
+func BenchmarkRulesMustBeValid(b *testing.B) {
+	r, err := ReadRulesFile("../../dictionaries/generator-rules-v2.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	// every two-key chord on the left hand, as symbols
+	r.Options.Symbols = true
+	left := LeftS | LeftT | LeftK | LeftP | LeftW | LeftH | LeftR | LeftA | LeftO
+	for _, k := range subsets(left, 3) {
+		r.Symbols = append(r.Symbols, KeyBinding{Name: k.String(), Keysym: "a", Mask: k})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.MustBeValid()
+	}
+}
@@ -0,0 +1,116 @@
+package lesson
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spilliams/steno/cli/dictionary"
+	"github.com/spilliams/steno/cli/orthography"
+	"github.com/spilliams/steno/cli/translate"
+)
+
+// CheckResult is what Check found for one lesson entry
+type CheckResult struct {
+	Entry
+	// Error is why the stroke couldn't be parsed, if it couldn't
+	Error string `json:"error,omitempty"`
+	// Translation is the text the dictionaries turn the stroke into
+	Translation string `json:"translation"`
+	// OK is true if the stroke parses and translates to the word
+	OK bool `json:"ok"`
+	// Alternatives are the other strokes that the dictionaries translate to
+	// the word, in steno order
+	Alternatives []string `json:"alternatives"`
+}
+
+// CheckOpts are the options for Check
+type CheckOpts struct {
+	// SuffixKeys are folded out of strokes that aren't in the dictionaries
+	// (see translate.Translator). If nil, translate's defaults are used.
+	SuffixKeys []orthography.SuffixKey
+	// NoFolding turns suffix key folding off
+	NoFolding bool
+}
+
+// Check checks that every entry's stroke is valid in the stack's system, and
+// that the stack translates it to the entry's word. Strokes are translated the
+// way the translate command translates them, so phrases may be written as
+// several briefs separated by spaces. It also lists the other strokes the
+// stack offers for each word.
+func Check(entries []Entry, s *dictionary.Stack, opts CheckOpts) []CheckResult {
+	system := s.System()
+	results := make([]CheckResult, len(entries))
+	for i, e := range entries {
+		r := CheckResult{Entry: e, Alternatives: make([]string, 0)}
+		briefs, err := parseStrokes(system, e.Stroke)
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			t := translate.NewTranslator(s)
+			if opts.SuffixKeys != nil {
+				t.SetSuffixKeys(opts.SuffixKeys)
+			}
+			if opts.NoFolding {
+				t.SetSuffixKeys(nil)
+			}
+			for _, b := range briefs {
+				t.TranslateBrief(b)
+			}
+			r.Translation = strings.TrimSpace(t.Output().Text)
+			r.OK = r.Translation == strings.TrimSpace(e.Word)
+		}
+
+		own := ""
+		if len(briefs) == 1 {
+			own = system.BriefString(briefs[0])
+		}
+		for _, b := range s.ReverseLookup(strings.TrimSpace(e.Word)) {
+			if stroke := system.BriefString(b); stroke != own {
+				r.Alternatives = append(r.Alternatives, stroke)
+			}
+		}
+		results[i] = r
+	}
+	return results
+}
+
+// parseStrokes parses each of the space-separated briefs of a lesson stroke
+func parseStrokes(system *dictionary.System, stroke string) ([]*dictionary.Brief, error) {
+	fields := strings.Fields(stroke)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("stroke is blank")
+	}
+	briefs := make([]*dictionary.Brief, len(fields))
+	for i, field := range fields {
+		b, err := system.ParseBrief(field)
+		if err != nil {
+			return nil, err
+		}
+		briefs[i] = b
+	}
+	return briefs, nil
+}
+
+// WriteCheckText writes the given results to w, one per line: the line
+// number, word and stroke, then whether it's ok (or what's wrong with it),
+// then any alternative strokes.
+func WriteCheckText(w io.Writer, results []CheckResult) error {
+	for _, r := range results {
+		status := "ok"
+		switch {
+		case r.Error != "":
+			status = r.Error
+		case !r.OK:
+			status = fmt.Sprintf("translates to %q", r.Translation)
+		}
+		line := fmt.Sprintf("%d\t%s\t%s\t%s", r.Line, r.Word, r.Stroke, status)
+		if len(r.Alternatives) > 0 {
+			line += "\talso " + strings.Join(r.Alternatives, ", ")
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package lesson
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spilliams/steno/cli/dictionary"
+)
+
+// GenerateOpts are the options for Generate
+type GenerateOpts struct {
+	// Pattern, if set, is matched against each stroke written out in full
+	// (e.g. `^[STKPWHR]+EU[FRPBLGTSDZ]$` for one-syllable strokes with EU and
+	// a single final consonant)
+	Pattern *regexp.Regexp
+	// Words, if not nil, are the only words to write strokes for. The lesson
+	// keeps their order, unless it is ordered by frequency.
+	Words []string
+	// Progress and Learned leave out words that have been typed at least
+	// Learned times. If Learned is 0, no words are left out.
+	Progress map[string]int
+	Learned  int
+	// Frequencies, if set, orders the lesson by word frequency, most frequent
+	// first. Words that aren't ranked go last.
+	Frequencies *dictionary.Frequencies
+	// Limit is the most entries the lesson may have, or 0 for no limit
+	Limit int
+	// Scorer picks the easiest stroke when a word has more than one. If it
+	// is nil, strokes are scored with dictionary.DefaultScoreWeights.
+	Scorer *dictionary.Scorer
+}
+
+// Generate builds a lesson from the words the given stack translates to. Each
+// word gets its easiest stroke that matches the pattern, and words with no
+// such stroke are left out. Only translations that are plain text (with no
+// Plover formatting or commands) count as words. Unless it is ordered
+// otherwise, the lesson is in the steno order of the strokes it uses. Words
+// that Frequencies doesn't rank keep that order as well.
+func Generate(s *dictionary.Stack, opts GenerateOpts) ([]Entry, error) {
+	system := s.System()
+	sc := opts.Scorer
+	if sc == nil {
+		var err error
+		if sc, err = dictionary.NewScorer(system, dictionary.DefaultScoreWeights); err != nil {
+			return nil, err
+		}
+	}
+
+	type candidate struct {
+		brief  *dictionary.Brief
+		stroke string
+		score  float64
+	}
+	best := make(map[string]candidate)
+	words := make([]string, 0)
+	consider := func(word string, b *dictionary.Brief) {
+		if opts.Learned > 0 && opts.Progress[word] >= opts.Learned {
+			return
+		}
+		stroke := system.BriefString(b)
+		if opts.Pattern != nil && !opts.Pattern.MatchString(stroke) {
+			return
+		}
+		c := candidate{b, stroke, sc.BriefScore(b)}
+		if prior, ok := best[word]; ok {
+			// ties go to the first stroke, in steno order
+			if c.score < prior.score {
+				best[word] = c
+			}
+			return
+		}
+		best[word] = c
+		words = append(words, word)
+	}
+
+	if opts.Words != nil {
+		for _, word := range opts.Words {
+			for _, b := range s.ReverseLookup(word) {
+				consider(word, b)
+			}
+		}
+	} else {
+		s.Flatten().Each(func(b *dictionary.Brief, translation string) bool {
+			if isPlainText(translation) {
+				consider(translation, b)
+			}
+			return true
+		})
+	}
+
+	if opts.Words == nil {
+		sort.SliceStable(words, func(i, j int) bool {
+			return best[words[i]].brief.Less(best[words[j]].brief)
+		})
+	}
+	if opts.Frequencies != nil {
+		sort.SliceStable(words, func(i, j int) bool {
+			a, aOK := opts.Frequencies.Rank(words[i])
+			b, bOK := opts.Frequencies.Rank(words[j])
+			return dictionary.RankLess(a, aOK, b, bOK)
+		})
+	}
+	if opts.Limit > 0 && len(words) > opts.Limit {
+		words = words[:opts.Limit]
+	}
+
+	entries := make([]Entry, len(words))
+	for i, word := range words {
+		entries[i] = Entry{Word: word, Stroke: best[word].stroke}
+	}
+	return entries, nil
+}
+
+// isPlainText returns true if the given translation is text that Plover types
+// as it is
+func isPlainText(translation string) bool {
+	return strings.TrimSpace(translation) != "" && !strings.ContainsAny(translation, "{}")
+}
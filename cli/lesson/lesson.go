@@ -0,0 +1,83 @@
+// Package lesson reads and writes Typey Type custom lessons: text files with
+// one word per line, followed by a tab and the stroke that writes it.
+package lesson
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Entry is one line of a lesson
+type Entry struct {
+	// Line is the line number the entry was read from, starting at 1, or 0
+	// if it wasn't read from a file
+	Line   int    `json:"line,omitempty"`
+	Word   string `json:"word"`
+	Stroke string `json:"stroke"`
+}
+
+// Parse reads a lesson. Blank lines are skipped; any other line without a tab
+// is an error.
+func Parse(r io.Reader) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		i := strings.IndexByte(line, '\t')
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected a word and a stroke separated by a tab, got %q", lineNumber, line)
+		}
+		entries = append(entries, Entry{
+			Line:   lineNumber,
+			Word:   line[:i],
+			Stroke: strings.TrimSpace(line[i+1:]),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ReadFile reads a lesson from the given file (see Parse)
+func ReadFile(filename string) ([]Entry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return entries, nil
+}
+
+// Write writes the given entries to w as a lesson, one per line
+func Write(w io.Writer, entries []Entry) error {
+	for _, e := range entries {
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", e.Word, e.Stroke); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFile writes the given entries to the given file as a lesson
+func WriteFile(entries []Entry, filename string) error {
+	buf := new(bytes.Buffer)
+	if err := Write(buf, entries); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, buf.Bytes(), 0644)
+}
@@ -0,0 +1,130 @@
+package lesson
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/spilliams/steno/cli/dictionary"
+)
+
+func TestParse(t *testing.T) {
+	entries, err := Parse(strings.NewReader("skip\tSKEUP\r\n\nskill\tSKEUL \na lot\tAEU HROT\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Entry{
+		{Line: 1, Word: "skip", Stroke: "SKEUP"},
+		{Line: 3, Word: "skill", Stroke: "SKEUL"},
+		{Line: 4, Word: "a lot", Stroke: "AEU HROT"},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %v, got %v", expected, entries)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := Write(buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "skip\tSKEUP\nskill\tSKEUL\na lot\tAEU HROT\n" {
+		t.Errorf("expected entries to be written one per line, got %q", buf.String())
+	}
+
+	_, err = Parse(strings.NewReader("skip\tSKEUP\nskill SKEUL\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected a line without a tab to be an error on line 2, got %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	d := dictionary.NewDictionary()
+	if err := json.Unmarshal([]byte(`{"SKEUP": "skip", "SKEUL": "skill", "STKEUL": "skill", "AEU": "a", "HROT": "lot", "-G": "{^ing}", "SKEU": "ski"}`), d); err != nil {
+		t.Fatal(err)
+	}
+	s := dictionary.NewStack()
+	s.Push("main", d)
+
+	entries := []Entry{
+		{Line: 1, Word: "skill", Stroke: "SKEUL"},
+		{Line: 2, Word: "a lot", Stroke: "AEU HROT"},
+		{Line: 3, Word: "skiing", Stroke: "SKEU/-G"},
+		{Line: 4, Word: "skip", Stroke: "SKEUL"},
+		{Line: 5, Word: "skid", Stroke: "SKEUD"},
+		{Line: 6, Word: "bad", Stroke: "XYZ"},
+	}
+	results := Check(entries, s, CheckOpts{})
+	type summary struct {
+		ok           bool
+		translation  string
+		alternatives []string
+	}
+	expected := []summary{
+		{true, "skill", []string{"STKEUL"}},
+		{true, "a lot", []string{}},
+		{true, "skiing", []string{}},
+		{false, "skill", []string{"SKEUP"}},
+		{false, "skied", []string{}},
+		{false, "", []string{}},
+	}
+	for i, r := range results {
+		actual := summary{r.OK, r.Translation, r.Alternatives}
+		if !reflect.DeepEqual(actual, expected[i]) {
+			t.Errorf("line %d: expected %v, got %v (%s)", r.Line, expected[i], actual, r.Error)
+		}
+	}
+	if results[5].Error == "" {
+		t.Errorf("expected a stroke out of steno order to be an error")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	d := dictionary.NewDictionary()
+	if err := json.Unmarshal([]byte(`{"STEUF": "stiff", "SKEUP": "skip", "SKEUL": "skill", "STKEUL": "skill", "SKEU": "ski", "KHEUP": "chip", "SKEUPD": "skipped", "-G": "{^ing}", "TEFT": "test"}`), d); err != nil {
+		t.Fatal(err)
+	}
+	s := dictionary.NewStack()
+	s.Push("main", d)
+	pattern := regexp.MustCompile(`^[STKPWHR]+EU[FRPBLGTSDZ]$`)
+
+	entries, err := Generate(s, GenerateOpts{Pattern: pattern})
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := make([]string, len(entries))
+	for i, e := range entries {
+		words[i] = e.Word + " " + e.Stroke
+	}
+	// skill is also STKEUL, which sorts first, but the lesson is ordered by
+	// the strokes it uses
+	expected := []string{"stiff STEUF", "skip SKEUP", "skill SKEUL", "chip KHEUP"}
+	if !reflect.DeepEqual(words, expected) {
+		t.Errorf("expected %v, got %v", expected, words)
+	}
+
+	entries, err = Generate(s, GenerateOpts{
+		Pattern:     pattern,
+		Progress:    map[string]int{"chip": 100, "skip": 3},
+		Learned:     100,
+		Frequencies: dictionary.NewFrequencies([]string{"the", "skip", "chip", "skill"}),
+		Limit:       2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedEntries := []Entry{{Word: "skip", Stroke: "SKEUP"}, {Word: "skill", Stroke: "SKEUL"}}
+	if !reflect.DeepEqual(entries, expectedEntries) {
+		t.Errorf("expected learned words left out and the rest by frequency, got %v", entries)
+	}
+
+	entries, err = Generate(s, GenerateOpts{Words: []string{"test", "skipping", "ski"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedEntries = []Entry{{Word: "test", Stroke: "TEFT"}, {Word: "ski", Stroke: "SKEU"}}
+	if !reflect.DeepEqual(entries, expectedEntries) {
+		t.Errorf("expected the words in the list that have strokes, in order, got %v", entries)
+	}
+}
@@ -1,19 +1,28 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/cli"
 	"github.com/spf13/cobra"
 	"github.com/spilliams/steno/cli/dictionary"
+	"github.com/spilliams/steno/cli/lesson"
+	"github.com/spilliams/steno/cli/orthography"
+	"github.com/spilliams/steno/cli/translate"
 	"github.com/spilliams/steno/cli/typeyprogress"
 )
 
 var verbose bool
+var systemFile string
 
 func main() {
 	cobra.OnInitialize(initLogger)
@@ -38,18 +47,80 @@ func newRootCmd() *cobra.Command {
 
 	cmd.AddCommand(newMergeProgressCmd())
 	cmd.AddCommand(newCleanProgressCmd())
+	cmd.AddCommand(newProgressStatsCmd())
+	cmd.AddCommand(newProgressCmd())
 	cmd.AddCommand(newGenerateDictionaryCmd())
-	cmd.AddCommand(newCompareDictionariesCmd())
+	cmd.AddCommand(newDesignRulesCmd())
+	cmd.AddCommand(newDiffDictionariesCmd())
+	cmd.AddCommand(newStackCmd())
+	cmd.AddCommand(newConvertDictionaryCmd())
+	cmd.AddCommand(newNormalizeDictionaryCmd())
+	cmd.AddCommand(newTranslateCmd())
+	cmd.AddCommand(newLessonCmd())
+	cmd.AddCommand(newGitMergeDriverCmd())
+	cmd.AddCommand(newTextconvCmd())
 
 	cmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "turn this on to get MORE")
+	cmd.PersistentFlags().StringVar(&systemFile, "system", "", "A JSON file defining the steno system strokes are written in (defaults to English Stenotype)")
 
 	return cmd
 }
 
+// readSystem returns the steno system given by the --system flag
+func readSystem() (*dictionary.System, error) {
+	if systemFile == "" {
+		return dictionary.English, nil
+	}
+	return dictionary.ReadSystemDefinitionFile(systemFile)
+}
+
+// readScorer returns a scorer for the given system, with the weights in the
+// given file, or the default weights if there is no file
+func readScorer(system *dictionary.System, weightsFile string) (*dictionary.Scorer, error) {
+	weights := dictionary.DefaultScoreWeights
+	if weightsFile != "" {
+		var err error
+		if weights, err = dictionary.ReadScoreWeightsFile(weightsFile); err != nil {
+			return nil, err
+		}
+	}
+	return dictionary.NewScorer(system, weights)
+}
+
+// checkFormat returns an error unless the value of the named format flag is
+// one of the given formats, or text or json if none are given
+func checkFormat(flag, format string, formats ...string) error {
+	if len(formats) == 0 {
+		formats = []string{"text", "json"}
+	}
+	for _, f := range formats {
+		if format == f {
+			return nil
+		}
+	}
+	expected := formats[len(formats)-1]
+	if len(formats) > 1 {
+		expected = strings.Join(formats[:len(formats)-1], ", ") + " or " + expected
+	}
+	return fmt.Errorf("unknown %s %s (expected %s)", flag, format, expected)
+}
+
+// writeJSON writes v to w as indented JSON, without escaping HTML characters
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
 func newMergeProgressCmd() *cobra.Command {
 	var outputFile string
+	var strategyName string
+	var baseFile string
+	var format string
+	var cleanOpts typeyprogress.CleanOpts
 	cmd := &cobra.Command{
-		Use:     "merge-progress <a> <b>",
+		Use:     "merge-progress <a> <b> [--base ancestor.json]",
 		Aliases: []string{"merge"},
 		Args:    cobra.ExactArgs(2),
 		Short:   "Merges 2 Typey Type progress files into 1.",
@@ -58,27 +129,80 @@ the input files. If no output file is specified, the first arg will be used as
 output.
 
 This command assumes the JSON objects will be a map of string to int. In the
-event of a key collision, the values will be summed.`,
+event of a key collision, the --strategy decides the count: sum adds the counts
+together, max takes the larger of them (so merging a file with a copy of itself
+changes nothing), and prefer-newer takes the count from whichever file was
+modified last.
+
+If both files were copied from a common ancestor (e.g. when syncing progress
+between two machines), give it with --base for a three-way merge instead. Each
+word gets the ancestor's count plus the progress made on each side, so nothing
+is counted twice. The words that changed on both sides are printed.
+
+Every file is cleaned first, the same way clean-progress cleans it, except
+that words made only of punctuation are kept unless --keep-punctuation=false
+is given.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			strategy, err := typeyprogress.ParseMergeStrategy(strategyName)
+			if err != nil {
+				return err
+			}
+			if baseFile != "" && cmd.Flags().Changed("strategy") {
+				return fmt.Errorf("--strategy can't be used with --base")
+			}
+			if err := checkFormat("format", format); err != nil {
+				return err
+			}
+
 			a, err := typeyprogress.ReadFile(args[0])
 			if err != nil {
 				return err
 			}
-			a = typeyprogress.Clean(a)
+			a = cleanProgress(args[0], a, cleanOpts)
 			log.WithField("a", a).Debug("json read")
 
 			b, err := typeyprogress.ReadFile(args[1])
 			if err != nil {
 				return err
 			}
-			b = typeyprogress.Clean(b)
+			b = cleanProgress(args[1], b, cleanOpts)
 			log.WithField("b", b).Debug("json read")
 
-			c, err := typeyprogress.Merge(a, b)
-			if err != nil {
-				return err
+			var c map[string]int
+			switch {
+			case baseFile != "":
+				base, err := typeyprogress.ReadFile(baseFile)
+				if err != nil {
+					return err
+				}
+				base = cleanProgress(baseFile, base, cleanOpts)
+				var changes []typeyprogress.Change
+				c, changes = typeyprogress.Merge3(base, a, b)
+				log.WithField("changed on both sides", len(changes)).Info("three-way merge done")
+				out := cmd.OutOrStdout()
+				if format == "json" {
+					err = writeJSON(out, changes)
+				} else {
+					err = typeyprogress.WriteChangesText(out, changes)
+				}
+				if err != nil {
+					return err
+				}
+			case strategy == typeyprogress.MergePreferNewer:
+				newer, err := isNewer(args[0], args[1])
+				if err != nil {
+					return err
+				}
+				if newer {
+					c = typeyprogress.MergeWith(b, a, strategy)
+				} else {
+					c = typeyprogress.MergeWith(a, b, strategy)
+				}
+			default:
+				c = typeyprogress.MergeWith(a, b, strategy)
 			}
 			log.WithField("c", c).Debug("json merged")
+			log.Info(typeyprogress.Summarize(c).String())
 			if outputFile == "" {
 				outputFile = args[0]
 			}
@@ -87,25 +211,67 @@ event of a key collision, the values will be summed.`,
 	}
 
 	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "The output file (optional)")
+	cmd.Flags().StringVar(&strategyName, "strategy", string(typeyprogress.MergeSum), "How to merge the counts of words in both files: sum, max or prefer-newer")
+	cmd.Flags().StringVar(&baseFile, "base", "", "The common ancestor of both files, for a three-way merge (optional)")
+	cmd.Flags().StringVarP(&format, "format", "f", "text", "The format of the three-way merge report: text or json")
+	addCleanFlags(cmd, &cleanOpts, true)
 
 	return cmd
 }
 
+// isNewer returns true if file a was modified after file b
+func isNewer(a, b string) (bool, error) {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return aInfo.ModTime().After(bInfo.ModTime()), nil
+}
+
 func newCleanProgressCmd() *cobra.Command {
 	var outputFile string
+	var format string
+	var cleanOpts typeyprogress.CleanOpts
 	cmd := &cobra.Command{
 		Use:     "clean-progress <a>",
 		Aliases: []string{"clean"},
 		Args:    cobra.ExactArgs(1),
 		Short:   "Cleans a Typey Type progress file",
-		Long:    "Cleans a Typey Type progress file. Trims whitespace from keys (while merging them with potential duplicates), and makes sure characters are not HTML-escaped)",
+		Long: `Cleans a Typey Type progress file. Makes sure characters are not
+HTML-escaped (decoding entities like &lt; and escapes like \u003c),
+normalizes Unicode to NFC, and trims whitespace from keys. With --fold-case,
+words that differ only in case are merged too.
+
+Keys that are left empty, or with nothing but punctuation and symbols, are
+dropped, unless --keep-punctuation is given (Typey Type's punctuation lessons
+record symbols like "!" as words). Keys that clean to the same word are merged
+by adding their counts together.
+
+Prints a report of the words that were merged and the keys that were dropped.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat("format", format); err != nil {
+				return err
+			}
 			a, err := typeyprogress.ReadFile(args[0])
 			if err != nil {
 				return err
 			}
-			a = typeyprogress.Clean(a)
 			log.WithField("a", a).Debug("json read")
+			a, report := typeyprogress.Clean(a, cleanOpts)
+
+			out := cmd.OutOrStdout()
+			if format == "json" {
+				err = writeJSON(out, report)
+			} else {
+				err = typeyprogress.WriteCleanReportText(out, report)
+			}
+			if err != nil {
+				return err
+			}
 			if outputFile == "" {
 				outputFile = args[0]
 			}
@@ -114,87 +280,1253 @@ func newCleanProgressCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "The output file (optional)")
+	cmd.Flags().StringVarP(&format, "format", "f", "text", "The format of the report: text or json")
+	addCleanFlags(cmd, &cleanOpts, false)
+
+	return cmd
+}
+
+// addCleanFlags adds the flags for the options of typeyprogress.Clean to the
+// given command. Commands that only clean progress on the way to doing
+// something else keep punctuation by default, so that they never lose real
+// progress.
+func addCleanFlags(cmd *cobra.Command, opts *typeyprogress.CleanOpts, keepPunctuation bool) {
+	cmd.Flags().BoolVar(&opts.FoldCase, "fold-case", false, "Merge words that differ only in case")
+	cmd.Flags().BoolVar(&opts.KeepPunctuation, "keep-punctuation", keepPunctuation, "Keep words made only of punctuation and symbols")
+}
+
+// cleanProgress cleans the given progress, read from the given file, and logs
+// what was done to it
+func cleanProgress(filename string, progress map[string]int, opts typeyprogress.CleanOpts) map[string]int {
+	cleaned, report := typeyprogress.Clean(progress, opts)
+	log.WithFields(log.Fields{
+		"filename": filename,
+		"merged":   len(report.Merged),
+		"dropped":  len(report.Dropped),
+	}).Info(report.Before.String())
+	return cleaned
+}
+
+func newProgressStatsCmd() *cobra.Command {
+	var wordLists []string
+	var format string
+	var top int
+	thresholds := typeyprogress.DefaultThresholds
+	cmd := &cobra.Command{
+		Use:     "progress-stats progress.json [--words lesson.tsv]",
+		Aliases: []string{"stats"},
+		Args:    cobra.ExactArgs(1),
+		Short:   "Sums up a Typey Type progress file",
+		Long: `Sums up a Typey Type progress file: how many words have been typed, how
+many of them are seen, memorised and learned, how many words have been typed
+each number of times, and which words have been typed the most and the least.
+
+As in Typey Type, a word is seen once it has been typed, and memorised once it
+has been typed 30 times. A word is learned once it has been typed 100 times.
+Each threshold can be changed with its own flag.
+
+With --words lesson.tsv (repeat it, or separate files with commas), the same
+sums are given for the words in each list, with words that have not been typed
+counted as unseen. A list has one word per line, and anything after a tab is
+ignored, so Typey Type lesson files (word<TAB>stroke) can be used as they are.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat("format", format); err != nil {
+				return err
+			}
+			if top < 0 {
+				return fmt.Errorf("--top must not be negative, got %d", top)
+			}
+			if err := thresholds.Validate(); err != nil {
+				return err
+			}
+			progress, err := typeyprogress.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+
+			all := typeyprogress.NewStats(progress, nil, thresholds, top)
+			all.Name = args[0]
+			stats := []typeyprogress.Stats{all}
+			for _, filename := range wordLists {
+				words, err := typeyprogress.ReadWordList(filename)
+				if err != nil {
+					return err
+				}
+				s := typeyprogress.NewStats(progress, words, thresholds, top)
+				s.Name = filename
+				stats = append(stats, s)
+			}
+
+			out := cmd.OutOrStdout()
+			if format == "json" {
+				return writeJSON(out, stats)
+			}
+			return typeyprogress.WriteStatsText(out, stats)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&wordLists, "words", nil, "Word lists or lesson files to break the stats down by (optional)")
+	cmd.Flags().StringVarP(&format, "format", "f", "text", "The output format: text or json")
+	cmd.Flags().IntVarP(&top, "top", "n", 10, "The number of most and least typed words to list")
+	cmd.Flags().IntVar(&thresholds.Seen, "seen", thresholds.Seen, "The times a word must be typed to be seen")
+	cmd.Flags().IntVar(&thresholds.Memorised, "memorised", thresholds.Memorised, "The times a word must be typed to be memorised")
+	cmd.Flags().IntVar(&thresholds.Learned, "learned", thresholds.Learned, "The times a word must be typed to be learned")
+
+	return cmd
+}
+
+func newProgressCmd() *cobra.Command {
+	var storeDir string
+	cmd := &cobra.Command{
+		Use:   "progress",
+		Short: "Keeps a history of snapshots of a Typey Type progress file",
+		Long: `Keeps a history of snapshots of a Typey Type progress file, so that
+progress isn't lost when the file is overwritten.
+
+Snapshots are kept in the --store directory, one file per snapshot, named by
+the time it was taken (in UTC). Snapshot files are never changed, so the
+directory can be kept in version control as it is.`,
+	}
+
+	cmd.PersistentFlags().StringVar(&storeDir, "store", "progress-history", "The directory to keep snapshots in")
+
+	cmd.AddCommand(newProgressSnapshotCmd(&storeDir))
+	cmd.AddCommand(newProgressLogCmd(&storeDir))
+	cmd.AddCommand(newProgressTrendCmd(&storeDir))
+
+	return cmd
+}
+
+func newProgressSnapshotCmd(storeDir *string) *cobra.Command {
+	var cleanOpts typeyprogress.CleanOpts
+	cmd := &cobra.Command{
+		Use:   "snapshot progress.json",
+		Args:  cobra.ExactArgs(1),
+		Short: "Saves a cleaned copy of a progress file as a new snapshot",
+		Long: `Saves a copy of a progress file as a new snapshot, cleaned the same way
+clean-progress cleans it, except that words made only of punctuation are kept
+unless --keep-punctuation=false is given. If nothing has changed since the
+latest snapshot, nothing is saved.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			progress, err := typeyprogress.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			progress = cleanProgress(args[0], progress, cleanOpts)
+			snapshot, saved, err := typeyprogress.NewStore(*storeDir).Save(progress, time.Now())
+			if err != nil {
+				return err
+			}
+			if !saved {
+				log.WithField("filename", snapshot.Filename).Info("progress hasn't changed since the latest snapshot")
+				return nil
+			}
+			log.WithFields(log.Fields{
+				"filename": snapshot.Filename,
+				"words":    snapshot.Words,
+				"strokes":  snapshot.Strokes,
+			}).Info("snapshot saved")
+			return nil
+		},
+	}
+
+	addCleanFlags(cmd, &cleanOpts, true)
+
+	return cmd
+}
+
+func newProgressLogCmd(storeDir *string) *cobra.Command {
+	var format string
+	cmd := &cobra.Command{
+		Use:   "log",
+		Args:  cobra.NoArgs,
+		Short: "Lists the snapshots in the store",
+		Long: `Lists the snapshots in the store, oldest first, with the number of words
+and strokes in each, and how many were gained since the snapshot before.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat("format", format); err != nil {
+				return err
+			}
+			snapshots, err := typeyprogress.NewStore(*storeDir).Snapshots()
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if format == "json" {
+				return writeJSON(out, snapshots)
+			}
+			return typeyprogress.WriteSnapshotsText(out, snapshots)
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", "text", "The output format: text or json")
+
+	return cmd
+}
+
+func newProgressTrendCmd(storeDir *string) *cobra.Command {
+	var format string
+	var since string
+	var top int
+	cmd := &cobra.Command{
+		Use:   "trend [--since 2006-01-02]",
+		Args:  cobra.NoArgs,
+		Short: "Shows the progress made over the snapshots in the store",
+		Long: `Shows the progress made over the snapshots in the store: the words and
+strokes gained in all and per day, the words gained and strokes typed on each
+day, the words whose counts grew the most, and the words that got stuck (seen
+but not memorised by the first snapshot, and not typed since).
+
+Days are in UTC. With --since, only snapshots taken on or after the given date
+are used.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat("format", format); err != nil {
+				return err
+			}
+			if top < 0 {
+				return fmt.Errorf("--top must not be negative, got %d", top)
+			}
+			snapshots, err := typeyprogress.NewStore(*storeDir).Snapshots()
+			if err != nil {
+				return err
+			}
+			if since != "" {
+				start, err := time.Parse("2006-01-02", since)
+				if err != nil {
+					return fmt.Errorf("--since must be a date like 2006-01-02: %v", err)
+				}
+				for len(snapshots) > 0 && snapshots[0].Time.Before(start) {
+					snapshots = snapshots[1:]
+				}
+			}
+			trend, err := typeyprogress.NewTrend(snapshots, typeyprogress.DefaultThresholds, top)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			if format == "json" {
+				return writeJSON(out, trend)
+			}
+			return typeyprogress.WriteTrendText(out, trend)
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", "text", "The output format: text or json")
+	cmd.Flags().StringVar(&since, "since", "", "The date to start the trend from (optional)")
+	cmd.Flags().IntVarP(&top, "top", "n", 10, "The number of fastest-growing and stuck words to list")
 
 	return cmd
 }
 
 func newGenerateDictionaryCmd() *cobra.Command {
 	var outputFile string
+	var against []string
+	var frequencyFile string
+	var collisions string
+	var format string
+	var errorFormat string
+	var weightsFile string
+	var scores bool
 	cmd := &cobra.Command{
 		Use:     "generate-dictionary r.json [--output dict.json]",
 		Aliases: []string{"gen-dict"},
 		Args:    cobra.ExactArgs(1),
 		Short:   "Generates a Plover dictionary file from a set of rules.",
-		Long: `Generates a Plover dictionary file from a set of rules. It uses
-the following factory options:
+		// validation errors are reported on their own, so the usage would
+		// only get in the way
+		SilenceUsage: true,
+		Long: `Generates a Plover dictionary file from a set of rules.
+
+Version 2 rules files declare their own factory options, key bindings and
+modifier masks:
+
+{
+  "version": 2,
+  "options": {"fingerspellings": true, "numbersLeft": "numbers"},
+  "layer": "-FRLG",
+  "modifiers": [{"name": "shift", "keysym": "Shift_L", "stroke": "-FRPLG"}],
+  "combinations": [{"modifiers": ["shift", "ctrl"], "nonstandard": false}],
+  "keys": [{"keysym": "Escape", "stroke": "SKP"}],
+  "symbols": [{"keysym": "bracketleft", "stroke": "PWR"}],
+  "mediaLayer": "-FRLGS",
+  "media": [{"keysym": "XF86AudioPlay", "stroke": "P"}],
+  "templates": {"default": "{#$combo}{^}{>}", "keys": {"Return": "{#$combo}{^}{-|}"}}
+}
+
+Number options are disabled, numbers, numbersHigh, functions or
+functionsHigh. If combinations are left out, every combination of two or more
+modifiers is generated. Symbols are only generated when the "symbols" option
+is true, and media keys when the "media" option is true. Media chords are
+combined with the media layer only; if none are listed, play, volume and
+brightness chords are generated on the left hand. Keysyms may be any X11
+keysym name Plover accepts (e.g. bracketleft, KP_Add or XF86AudioPlay).
+
+Templates decide how each entry is written. $combo is the key combo (e.g.
+Shift_L(Return)), $modifiers the modifier names, $key the key name and $keysym
+the key's keysym. Keys are overridden by name; the default template is
+{#$combo}{^}{>}.
+
+Generated strokes can be checked against your other dictionaries with
+--against main.json (repeat it, or separate files with commas, for a stack
+listed from the bottom up). Every generated stroke that is already defined is
+reported, ranked by the word it collides with: most frequent first, according
+to the --frequency list (one word per line, optionally followed by a tab and a
+count). With --collisions drop, colliding strokes are left out; with
+--collisions move, each is moved to its own chord plus the star (or, if that
+is taken, plus the first free key), and the report lists where it went.
+
+Strokes are scored by how hard they are to write: one point per key, plus
+1.5 for each stretch (adjacent fingers on opposite rows, the number bar, the
+outer pinky column, or the star with both index fingers down) and 1 for
+using both hands, less 0.5 for each finger pressing two keys. Lower is
+easier. The collision report shows the score of each stroke, and --scores
+prints the score of each key on the layer, so that easy chords can be kept
+for common keys like BackSpace. The weights can be changed with
+--weights weights.json:
+
+{"keys": 1, "stretch": 1.5, "sameFinger": -0.5, "split": 1, "keyCosts": {"-Z": 1}}
+
+Weights left out keep their defaults; key costs are added for each key named.
+With -f json, the scores and the collision report are each printed as an
+array, or, if both are asked for, as one object: {"scores": [...],
+"collisions": [...]}.
+
+If the rules are invalid, nothing is written. With --error-format json, the
+problems are printed as a JSON array instead of being logged. Each has a code
+(blank, duplicate, fingerspelling, keysym or template), the names of the
+chords at fault (a modifier and key combined are written as shift+escape), and
+the stroke at fault, if any, as both a keymask and a string.
+
+Version 1 rules files (a flat object of the 14 navigation keys, layer, shift,
+ctrl, alt and gui) use the following factory options:
 Non-standard modifier combinations: true,
 Fingerspellings: true,
 Left-hand numbers: Numbers 0-5
 Left-hand star-numbers: F1-F5 and F12`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			rules, err := dictionary.ReadRulesFile(args[0])
+			system, err := readSystem()
+			if err != nil {
+				return err
+			}
+			if err := checkFormat("error format", errorFormat); err != nil {
+				return err
+			}
+			if err := checkFormat("format", format); err != nil {
+				return err
+			}
+			scorer, err := readScorer(system, weightsFile)
+			if err != nil {
+				return err
+			}
+			rules, err := dictionary.ReadSystemRulesFile(args[0], system)
 			if err != nil {
 				return err
 			}
 			log.WithField("rules", rules).Info("rules file read")
+			if rules.Version < dictionary.RulesV2 {
+				rules.Options = dictionary.DefaultFactoryOpts
+			}
 			if errs := rules.MustBeValid(); len(errs) > 0 {
-				for _, err := range errs {
-					log.Error(err.Error())
+				if errorFormat == "json" {
+					if err := writeJSON(cmd.OutOrStdout(), errs); err != nil {
+						return err
+					}
+				} else {
+					for _, err := range errs {
+						log.Error(err.Error())
+					}
 				}
 				return fmt.Errorf("rules file was invalid")
 			}
 			log.Info("rules are valid")
 
-			// TODO: make these factory options configurable from command line
-			f := dictionary.NewFactory(dictionary.FactoryOpts{
-				NonstandardModCombinations: true,
-				Fingerspellings:            true,
-				NumbersLeft:                dictionary.NumberOptionNumbers,
-				NumberStarsLeft:            dictionary.NumberOptionFunctions,
-			})
-			d := f.Generate(rules)
+			f := dictionary.NewFactory(rules.Options)
+			d, err := f.Generate(rules)
+			if err != nil {
+				return err
+			}
 
-			// encode d into a buffer of bytes
-			buf := new(bytes.Buffer)
-			enc := json.NewEncoder(buf)
-			enc.SetEscapeHTML(false)
-			enc.SetIndent("", "  ")
-			if err := enc.Encode(d); err != nil {
+			var report generateReport
+			if scores {
+				report.Scores = rules.LayerScores(scorer)
+			}
+			if len(against) > 0 {
+				if report.Collisions, err = resolveCollisions(d, scorer, against, frequencyFile, collisions); err != nil {
+					return err
+				}
+			}
+			if err := report.write(cmd.OutOrStdout(), format); err != nil {
 				return err
 			}
 
-			// write those bytes to a file
 			log.WithField("filename", outputFile).Info("writing dictionary file")
-			return ioutil.WriteFile(outputFile, buf.Bytes(), 0644)
+			return d.WriteFile(outputFile)
 		},
 	}
 
 	cmd.Flags().StringVarP(&outputFile, "output", "o", "dict.json", "The name to save the dictionary file as (optional)")
+	cmd.Flags().StringSliceVar(&against, "against", nil, "Dictionaries to check the generated strokes against, from the bottom of the stack to the top (optional)")
+	cmd.Flags().StringVar(&frequencyFile, "frequency", "", "A word frequency list to rank collisions by (optional)")
+	cmd.Flags().StringVar(&collisions, "collisions", "keep", "What to do with colliding strokes: keep, drop or move")
+	cmd.Flags().StringVarP(&format, "format", "f", "text", "The format of the collision report and scores: text or json")
+	cmd.Flags().StringVar(&errorFormat, "error-format", "text", "The format of validation errors: text (logged) or json (printed)")
+	cmd.Flags().StringVar(&weightsFile, "weights", "", "A JSON file of weights to score strokes with (optional)")
+	cmd.Flags().BoolVar(&scores, "scores", false, "Print the score of each key's stroke on the layer")
+
+	return cmd
+}
+
+// resolveCollisions checks the generated dictionary against the given stack of
+// dictionaries, applies the named collision policy to it, and returns the
+// collisions.
+func resolveCollisions(d *dictionary.Dictionary, scorer *dictionary.Scorer, against []string, frequencyFile, policyName string) ([]dictionary.Collision, error) {
+	var policy dictionary.CollisionPolicy
+	switch policyName {
+	case "keep":
+		policy = dictionary.KeepCollisions
+	case "drop":
+		policy = dictionary.DropCollisions
+	case "move":
+		policy = dictionary.MoveCollisions
+	default:
+		return nil, fmt.Errorf("unknown collision policy %s (expected keep, drop or move)", policyName)
+	}
+	base, err := dictionary.ReadSystemStackFiles(against, d.System())
+	if err != nil {
+		return nil, err
+	}
+	var freq *dictionary.Frequencies
+	if frequencyFile != "" {
+		if freq, err = dictionary.ReadFrequencyFile(frequencyFile); err != nil {
+			return nil, err
+		}
+	}
+
+	collisions := dictionary.FindCollisions(d, base, freq, scorer)
+	dictionary.ResolveCollisions(d, base, collisions, policy, scorer)
+	moved := 0
+	dropped := 0
+	for _, c := range collisions {
+		if c.MovedTo != "" {
+			moved++
+		} else if c.Dropped {
+			dropped++
+		}
+	}
+	log.WithFields(log.Fields{
+		"collisions": len(collisions),
+		"moved":      moved,
+		"dropped":    dropped,
+	}).Info("generated strokes checked against base dictionaries")
+
+	return collisions, nil
+}
+
+// generateReport is what generate-dictionary prints: the scores of the layer's
+// strokes and the collisions with the base dictionaries, each if asked for
+type generateReport struct {
+	Scores     []dictionary.ChordScore `json:"scores"`
+	Collisions []dictionary.Collision  `json:"collisions"`
+}
+
+// write prints the receiver. As JSON, a report with only one part is printed
+// as that part's array, and a report with both is printed as one object.
+func (r generateReport) write(w io.Writer, format string) error {
+	if r.Scores != nil {
+		total := 0.0
+		for _, s := range r.Scores {
+			total += s.Score
+		}
+		if len(r.Scores) > 0 {
+			log.WithField("mean", fmt.Sprintf("%.2f", total/float64(len(r.Scores)))).Info("layer strokes scored")
+		}
+	}
+
+	if format == "json" {
+		var v interface{} = r
+		switch {
+		case r.Scores == nil && r.Collisions == nil:
+			return nil
+		case r.Collisions == nil:
+			v = r.Scores
+		case r.Scores == nil:
+			v = r.Collisions
+		}
+		return writeJSON(w, v)
+	}
+	for _, s := range r.Scores {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%.1f\n", s.Name, s.Stroke, s.Score); err != nil {
+			return err
+		}
+	}
+	if r.Collisions == nil {
+		return nil
+	}
+	return dictionary.WriteCollisionsText(w, r.Collisions)
+}
+
+func newDesignRulesCmd() *cobra.Command {
+	var outputFile string
+	var against []string
+	var count int
+	var budget int
+	var weightsFile string
+	cmd := &cobra.Command{
+		Use:   "design-rules design.json [--against main.json] [--output rules.json]",
+		Args:  cobra.ExactArgs(1),
+		Short: "Searches for generator rules that satisfy a set of constraints",
+		Long: `Searches for generator rules that satisfy a set of constraints, and
+writes the best ones as version 2 rules files.
+
+A design file is a version 2 rules file in which any stroke (the layer, a
+modifier or a key) may be left blank for the search to choose. The strokes
+that are given are pinned. It also says which keys each kind of chord may use:
+
+{
+  "modifierKeys": "-FRPBLGTSDZ",
+  "keyKeys": "STKPWHRAO*EU",
+  "maxKeys": 4,
+  "layer": "-FRLG",
+  "modifiers": [{"name": "shift", "keysym": "Shift_L"}],
+  "keys": [{"keysym": "Escape", "stroke": "SKP"}, {"keysym": "BackSpace"}]
+}
+
+The layer and modifiers are made of modifier keys, with each modifier adding up
+to two keys of its own to the layer. Key chords are made of up to maxKeys key
+keys. Every generated stroke must pass the same checks as generate-dictionary,
+and must not be a single stroke in any of the --against dictionaries.
+
+The search gives each binding, in order, the easiest chord that still fits, and
+backtracks when a binding has none left. Candidates are ranked by the mean
+score of the strokes they generate, where lower scores are easier to write
+(see generate-dictionary for how strokes are scored, and for the --weights
+file). With --count greater than 1, the candidates are written to numbered
+files (e.g. rules-1.json).`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if count < 1 {
+				return fmt.Errorf("--count must be at least 1, got %d", count)
+			}
+			if budget < 1 {
+				return fmt.Errorf("--budget must be at least 1, got %d", budget)
+			}
+			system, err := readSystem()
+			if err != nil {
+				return err
+			}
+			design, err := dictionary.ReadSystemDesignFile(args[0], system)
+			if err != nil {
+				return err
+			}
+			scorer, err := readScorer(system, weightsFile)
+			if err != nil {
+				return err
+			}
+			opts := dictionary.DesignOpts{Score: scorer.Score, Candidates: count, Budget: budget}
+			if len(against) > 0 {
+				if opts.Against, err = dictionary.ReadSystemStackFiles(against, system); err != nil {
+					return err
+				}
+			}
+			candidates, err := design.Search(opts)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			for i, c := range candidates {
+				filename := outputFile
+				if count > 1 {
+					ext := filepath.Ext(outputFile)
+					filename = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(outputFile, ext), i+1, ext)
+				}
+				fmt.Fprintf(out, "%d\t%.2f\t%s\n", i+1, c.Score, filename)
+				if err := c.Rules.WriteFile(filename); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "rules.json", "The name to save the rules file as (optional)")
+	cmd.Flags().StringSliceVar(&against, "against", nil, "Dictionaries whose strokes the generated strokes must avoid (optional)")
+	cmd.Flags().IntVarP(&count, "count", "n", 1, "The number of candidates to write")
+	cmd.Flags().IntVar(&budget, "budget", dictionary.DefaultDesignBudget, "The most chords to try before giving up")
+	cmd.Flags().StringVar(&weightsFile, "weights", "", "A JSON file of weights to score strokes with (optional)")
+
+	return cmd
+}
+
+func newDiffDictionariesCmd() *cobra.Command {
+	var format string
+	cmd := &cobra.Command{
+		Use:     "diff-dictionaries a.json b.json [--format text|json|markdown]",
+		Aliases: []string{"diff-dict", "compare-dictionaries", "cmp-dict"},
+		Args:    cobra.ExactArgs(2),
+		Short:   "Compares two dictionary files and sorts their entries into groups",
+		Long: `Compares two dictionary files and sorts their entries into groups:
+strokes only in A, strokes only in B, strokes with the same translation in both,
+strokes with a different translation in each (conflicts), and translations that
+the two dictionaries produce from different strokes.
+
+Exits non-zero if there are any conflicts.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat("format", format, "text", "json", "markdown", "md"); err != nil {
+				return err
+			}
+			system, err := readSystem()
+			if err != nil {
+				return err
+			}
+			a, err := dictionary.ReadSystemFile(args[0], system)
+			if err != nil {
+				return err
+			}
+			b, err := dictionary.ReadSystemFile(args[1], system)
+			if err != nil {
+				return err
+			}
+
+			diff := dictionary.NewDiff(a, b)
+			out := cmd.OutOrStdout()
+			switch format {
+			case "text":
+				err = diff.WriteText(out)
+			case "json":
+				err = writeJSON(out, diff)
+			case "markdown", "md":
+				err = diff.WriteMarkdown(out)
+			}
+			if err != nil {
+				return err
+			}
+
+			if diff.HasConflicts() {
+				return fmt.Errorf("%d strokes conflict between %s and %s", len(diff.Conflicts), args[0], args[1])
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", "text", "The output format: text, json or markdown")
+
+	return cmd
+}
+
+func newStackCmd() *cobra.Command {
+	var outputFile string
+	var format string
+	var shadowedOnly bool
+	cmd := &cobra.Command{
+		Use:   "stack bottom.json [...] top.json [--output flat.json]",
+		Args:  cobra.MinimumNArgs(1),
+		Short: "Resolves a stack of dictionaries the way Plover does",
+		Long: `Resolves a stack of dictionaries the way Plover does. Dictionaries
+are listed from the bottom of the stack to the top (e.g. main.json first, and
+your own dictionaries after it). When more than one dictionary defines a stroke,
+the one highest in the stack wins.
+
+For each stroke this prints the winning translation, the dictionary it came
+from, and the entries it shadows. If an output file is given, the flattened
+effective dictionary is written to it.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat("format", format); err != nil {
+				return err
+			}
+			system, err := readSystem()
+			if err != nil {
+				return err
+			}
+			s, err := dictionary.ReadSystemStackFiles(args, system)
+			if err != nil {
+				return err
+			}
+
+			resolutions := s.Resolve()
+			if shadowedOnly {
+				filtered := make([]dictionary.Resolution, 0)
+				for _, r := range resolutions {
+					if len(r.Shadowed) > 0 {
+						filtered = append(filtered, r)
+					}
+				}
+				resolutions = filtered
+			}
+
+			out := cmd.OutOrStdout()
+			switch format {
+			case "text":
+				for _, r := range resolutions {
+					fmt.Fprintf(out, "%s\t%s\t(%s)\n", r.Stroke, r.Winner.Translation, r.Winner.Source)
+					for _, shadowed := range r.Shadowed {
+						fmt.Fprintf(out, "  shadows\t%s\t(%s)\n", shadowed.Translation, shadowed.Source)
+					}
+				}
+			case "json":
+				if err := writeJSON(out, resolutions); err != nil {
+					return err
+				}
+			}
+
+			if outputFile == "" {
+				return nil
+			}
+			log.WithField("filename", outputFile).Info("writing flattened dictionary file")
+			return s.Flatten().WriteFile(outputFile)
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "The file to write the flattened dictionary to (optional)")
+	cmd.Flags().StringVarP(&format, "format", "f", "text", "The output format: text or json")
+	cmd.Flags().BoolVar(&shadowedOnly, "shadowed-only", false, "Only print strokes that shadow another entry")
 
 	return cmd
 }
 
-func newCompareDictionariesCmd() *cobra.Command {
+func newConvertDictionaryCmd() *cobra.Command {
 	return &cobra.Command{
-		Use:     "compare-dictionaries a.json b.json",
-		Aliases: []string{"cmp-dict"},
+		Use:     "convert-dictionary in.rtf out.json",
+		Aliases: []string{"convert-dict"},
 		Args:    cobra.ExactArgs(2),
-		Short:   "Compares two dictionary files and prints out the collisions in their chords",
+		Short:   "Converts a dictionary between Plover JSON and RTF/CRE",
+		Long: `Converts a dictionary between Plover JSON and RTF/CRE. The format of
+each file is picked from its extension: files ending in .rtf are RTF/CRE, and
+anything else is Plover JSON.
+
+Plover operators with no RTF/CRE equivalent (such as key combos) are written
+into {\*\cxplovermeta ...} groups, and are read back from them.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			a, err := dictionary.ReadFile(args[0])
+			system, err := readSystem()
 			if err != nil {
 				return err
 			}
-			b, err := dictionary.ReadFile(args[1])
+			d, err := dictionary.ReadSystemFile(args[0], system)
 			if err != nil {
 				return err
 			}
+			log.WithFields(log.Fields{
+				"filename": args[1],
+				"entries":  d.Len(),
+			}).Info("writing dictionary file")
+			return d.WriteFile(args[1])
+		},
+	}
+}
+
+func newNormalizeDictionaryCmd() *cobra.Command {
+	var outputFile string
+	cmd := &cobra.Command{
+		Use:     "normalize-dictionary d.json [--output d.json]",
+		Aliases: []string{"norm-dict"},
+		Args:    cobra.ExactArgs(1),
+		Short:   "Rewrites every stroke in a dictionary into canonical steno order",
+		Long: `Rewrites every stroke in a Plover JSON dictionary into canonical
+steno order (e.g. SHR*FRLG stays as it is, but SHRFRLG becomes SHR-FRLG).
+Entries whose strokes come out identical are merged. If their translations
+disagree, the entry that was already canonical is kept and the conflict is
+reported. Keys are written back out in sorted order.
 
-			errs := a.MustNotCollideWith(b)
-			if len(errs) > 0 {
-				log.Warn("You may want to perform lookups to see if there are other briefs for the definitions. You may also check for multi-brief combinations (such as `RE` to mean `{re^}` for a word starting in \"re\")")
+Fails without writing anything if any stroke cannot be parsed. If no output
+file is specified, the input file will be overwritten.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			raw, err := dictionary.ReadRawFile(args[0])
+			if err != nil {
+				return err
+			}
+			system, err := readSystem()
+			if err != nil {
+				return err
+			}
+			d, report, err := dictionary.Normalize(raw, system)
+			if err != nil {
+				return err
+			}
+
+			for from, to := range report.Rewritten {
+				log.WithFields(log.Fields{
+					"from": from,
+					"to":   to,
+				}).Debug("stroke rewritten")
+			}
+			for _, group := range report.Merged {
+				log.WithField("entries", group).Info("duplicate entries merged")
+			}
+			for _, group := range report.Conflicts {
+				log.WithFields(log.Fields{
+					"kept":      group[0],
+					"discarded": group[1:],
+				}).Warn("conflicting entries merged")
+			}
+			log.WithFields(log.Fields{
+				"rewritten": len(report.Rewritten),
+				"merged":    len(report.Merged),
+				"conflicts": len(report.Conflicts),
+			}).Info("dictionary normalized")
+
+			if outputFile == "" {
+				outputFile = args[0]
+			}
+			return d.WriteFile(outputFile)
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "The output file (optional)")
+
+	return cmd
+}
+
+func newTranslateCmd() *cobra.Command {
+	var dictionaries []string
+	var format string
+	var suffixKeysFile string
+	var noFolding bool
+	cmd := &cobra.Command{
+		Use:   "translate -d main.json [-d user.json ...] [STROKE/STROKE ...]",
+		Short: "Translates a sequence of strokes into the text Plover would type",
+		Long: `Translates a sequence of strokes into the text Plover would type.
+Dictionaries are given from the bottom of the stack to the top, as with the
+stack command. Strokes are read from the arguments, or from stdin if there are
+none, and may be separated by whitespace or slashes.
+
+The longest multi-stroke entry wins, * undoes the last translation, and
+Plover's formatting operators are applied. Key combos are printed separately
+from the text, along with the position in the text at which they were sent.
+
+Strokes missing from the dictionaries are retried with a suffix key (-Z, -D, -S
+or -G) folded out, and suffixes are attached using English orthography rules.
+A different suffix key table can be given as a JSON file, such as
+[{"key": "-G", "suffix": "ing"}].`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat("format", format); err != nil {
+				return err
+			}
+			system, err := readSystem()
+			if err != nil {
+				return err
+			}
+			s, err := dictionary.ReadSystemStackFiles(dictionaries, system)
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 0 {
+				in, err := ioutil.ReadAll(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				args = strings.Fields(string(in))
+			}
+			t := translate.NewTranslator(s)
+			if suffixKeysFile != "" {
+				keys, err := orthography.ReadSuffixKeysFile(suffixKeysFile, system)
+				if err != nil {
+					return err
+				}
+				t.SetSuffixKeys(keys)
+			}
+			if noFolding {
+				t.SetSuffixKeys(nil)
+			}
+			for _, arg := range args {
+				b, err := system.ParseBrief(arg)
+				if err != nil {
+					return err
+				}
+				t.TranslateBrief(b)
+			}
+			output := t.Output()
+
+			out := cmd.OutOrStdout()
+			switch format {
+			case "text":
+				fmt.Fprintln(out, output.Text)
+				for _, combo := range output.KeyCombos {
+					fmt.Fprintf(out, "{#%s} at %d\n", combo.Combo, combo.Offset)
+				}
+			case "json":
+				return writeJSON(out, output)
 			}
 			return nil
 		},
 	}
+
+	cmd.Flags().StringSliceVarP(&dictionaries, "dictionary", "d", nil, "A dictionary to translate with. Repeat this flag to build a stack, bottom first")
+	cmd.Flags().StringVarP(&format, "format", "f", "text", "The output format: text or json")
+	cmd.Flags().StringVar(&suffixKeysFile, "suffix-keys", "", "A JSON file of suffix keys to fold (optional)")
+	cmd.Flags().BoolVar(&noFolding, "no-folding", false, "Turn off suffix key folding")
+	cmd.MarkFlagRequired("dictionary")
+
+	return cmd
+}
+
+func newLessonCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lesson",
+		Short: "Works with Typey Type custom lessons",
+		Long: `Works with Typey Type custom lessons: text files with one word per line,
+followed by a tab and the stroke that writes it (e.g. "skip<TAB>SKEUP").`,
+	}
+
+	cmd.AddCommand(newLessonCheckCmd())
+	cmd.AddCommand(newLessonGenerateCmd())
+
+	return cmd
+}
+
+func newLessonCheckCmd() *cobra.Command {
+	var dictionaries []string
+	var format string
+	var suffixKeysFile string
+	var noFolding bool
+	cmd := &cobra.Command{
+		Use:   "check lesson.txt -d main.json [-d user.json ...]",
+		Args:  cobra.ExactArgs(1),
+		Short: "Checks that a lesson's strokes write its words",
+		Long: `Checks that every stroke in a lesson is a valid stroke, and that the
+dictionaries translate it to the lesson's word. Dictionaries are given from the
+bottom of the stack to the top, as with the stack command, and strokes are
+translated the same way the translate command translates them. Every other
+stroke the dictionaries offer for the word is listed too.
+
+Exits non-zero if any stroke is invalid or writes something else.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat("format", format); err != nil {
+				return err
+			}
+			system, err := readSystem()
+			if err != nil {
+				return err
+			}
+			entries, err := lesson.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			s, err := dictionary.ReadSystemStackFiles(dictionaries, system)
+			if err != nil {
+				return err
+			}
+			opts := lesson.CheckOpts{NoFolding: noFolding}
+			if suffixKeysFile != "" {
+				if opts.SuffixKeys, err = orthography.ReadSuffixKeysFile(suffixKeysFile, system); err != nil {
+					return err
+				}
+			}
+
+			results := lesson.Check(entries, s, opts)
+			out := cmd.OutOrStdout()
+			if format == "json" {
+				err = writeJSON(out, results)
+			} else {
+				err = lesson.WriteCheckText(out, results)
+			}
+			if err != nil {
+				return err
+			}
+
+			problems := 0
+			for _, r := range results {
+				if !r.OK {
+					problems++
+				}
+			}
+			if problems > 0 {
+				return fmt.Errorf("%d of %d lesson entries have problems", problems, len(results))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&dictionaries, "dictionary", "d", nil, "A dictionary to check with. Repeat this flag to build a stack, bottom first")
+	cmd.Flags().StringVarP(&format, "format", "f", "text", "The output format: text or json")
+	cmd.Flags().StringVar(&suffixKeysFile, "suffix-keys", "", "A JSON file of suffix keys to fold (optional)")
+	cmd.Flags().BoolVar(&noFolding, "no-folding", false, "Turn off suffix key folding")
+	cmd.MarkFlagRequired("dictionary")
+
+	return cmd
+}
+
+func newLessonGenerateCmd() *cobra.Command {
+	var dictionaries []string
+	var outputFile string
+	var pattern string
+	var wordsFile string
+	var progressFile string
+	var learned int
+	var frequencyFile string
+	var limit int
+	var weightsFile string
+	cmd := &cobra.Command{
+		Use:   "generate -d main.json [-d user.json ...] [--pattern regexp] [--words list.txt] [--output lesson.txt]",
+		Args:  cobra.NoArgs,
+		Short: "Builds a lesson from the words in a stack of dictionaries",
+		Long: `Builds a lesson from the words in a stack of dictionaries. Dictionaries
+are given from the bottom of the stack to the top, as with the stack command.
+Only plain words are used, not translations with formatting or commands.
+
+With --pattern, only strokes that match the regular expression are used.
+Strokes are matched written out in full, so one-syllable strokes with EU and a
+single final consonant (like the Lapwing chapter 5 drill) are
+^[STKPWHR]+EU[FRPBLGTSDZ]$. With --words (one word per line, or a lesson
+file), only the words in the list are used, in the list's order.
+
+When a word has more than one stroke, the easiest is used (see
+generate-dictionary for how strokes are scored, and for the --weights file).
+With --progress, words that have been typed at least --learned times are left
+out. With --frequency, the lesson is ordered by word frequency, most frequent
+first; otherwise it is in the steno order of the strokes it uses (or the word
+list's order). --limit caps the number of words.
+
+The lesson is printed, unless --output is given.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			system, err := readSystem()
+			if err != nil {
+				return err
+			}
+			s, err := dictionary.ReadSystemStackFiles(dictionaries, system)
+			if err != nil {
+				return err
+			}
+			opts := lesson.GenerateOpts{Learned: learned, Limit: limit}
+			if pattern != "" {
+				if opts.Pattern, err = regexp.Compile(pattern); err != nil {
+					return fmt.Errorf("--pattern: %v", err)
+				}
+			}
+			if wordsFile != "" {
+				if opts.Words, err = typeyprogress.ReadWordList(wordsFile); err != nil {
+					return err
+				}
+			}
+			if progressFile != "" {
+				progress, err := typeyprogress.ReadFile(progressFile)
+				if err != nil {
+					return err
+				}
+				opts.Progress = cleanProgress(progressFile, progress, typeyprogress.CleanOpts{KeepPunctuation: true})
+			} else {
+				opts.Learned = 0
+			}
+			if frequencyFile != "" {
+				if opts.Frequencies, err = dictionary.ReadFrequencyFile(frequencyFile); err != nil {
+					return err
+				}
+			}
+			if opts.Scorer, err = readScorer(system, weightsFile); err != nil {
+				return err
+			}
+
+			entries, err := lesson.Generate(s, opts)
+			if err != nil {
+				return err
+			}
+			log.WithField("words", len(entries)).Info("lesson generated")
+			if outputFile == "" {
+				return lesson.Write(cmd.OutOrStdout(), entries)
+			}
+			return lesson.WriteFile(entries, outputFile)
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&dictionaries, "dictionary", "d", nil, "A dictionary to take words from. Repeat this flag to build a stack, bottom first")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "The name to save the lesson file as (optional)")
+	cmd.Flags().StringVar(&pattern, "pattern", "", "A regular expression strokes must match (optional)")
+	cmd.Flags().StringVar(&wordsFile, "words", "", "A word list or lesson file to take words from (optional)")
+	cmd.Flags().StringVar(&progressFile, "progress", "", "A Typey Type progress file, to leave out learned words (optional)")
+	cmd.Flags().IntVar(&learned, "learned", typeyprogress.DefaultThresholds.Learned, "The times a word must be typed to be learned")
+	cmd.Flags().StringVar(&frequencyFile, "frequency", "", "A word frequency list to order the lesson by (optional)")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 0, "The most words the lesson may have (0 for no limit)")
+	cmd.Flags().StringVar(&weightsFile, "weights", "", "A JSON file of weights to score strokes with (optional)")
+	cmd.MarkFlagRequired("dictionary")
+
+	return cmd
+}
+
+const gitSetupHelp = `To use it, tell git about the driver and the textconv helper (e.g. in
+.git/config):
+
+[merge "steno"]
+	name = steno JSON merge
+	driver = steno git-merge-driver %O %A %B
+[diff "steno"]
+	textconv = steno textconv
+
+and mark the files it should be used for in .gitattributes:
+
+notes/progress.json merge=steno diff=steno
+dictionaries/*.json merge=steno diff=steno`
+
+func newGitMergeDriverCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "git-merge-driver ancestor.json ours.json theirs.json",
+		Args:  cobra.ExactArgs(3),
+		Short: "Merges Typey Type progress files and dictionaries for git",
+		Long: `Merges Typey Type progress files and Plover dictionaries for git, key by
+key, instead of line by line. Git gives it the common ancestor, our version and
+their version of a file (%O %A %B), and the merged file is written over ours,
+sorted and formatted the same way as the other commands write it.
+
+Progress files get a three-way merge (see merge-progress --base), which never
+conflicts. For dictionaries, every stroke either side added, changed or
+removed is taken. Strokes that both sides changed in different ways keep our
+translation and are logged, and the merge fails so that git reports a
+conflict to resolve by hand.
+
+` + gitSetupHelp,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, err := jsonKind(args...)
+			if err != nil {
+				return err
+			}
+			if kind == progressKind {
+				files := make([]map[string]int, len(args))
+				for i, filename := range args {
+					if files[i], err = readProgressOrEmpty(filename); err != nil {
+						return err
+					}
+				}
+				c, changes := typeyprogress.Merge3(files[0], files[1], files[2])
+				for _, change := range changes {
+					log.WithFields(log.Fields{
+						"word":   change.Word,
+						"base":   change.Base,
+						"ours":   change.A,
+						"theirs": change.B,
+						"merged": change.Merged,
+					}).Info("changed on both sides")
+				}
+				return typeyprogress.WriteFile(c, args[1])
+			}
+
+			system, err := readSystem()
+			if err != nil {
+				return err
+			}
+			files := make([]*dictionary.Dictionary, len(args))
+			for i, filename := range args {
+				if files[i], err = readDictionaryOrEmpty(filename, system); err != nil {
+					return err
+				}
+			}
+			merged, conflicts := dictionary.Merge3(files[0], files[1], files[2])
+			if err := merged.WriteFile(args[1]); err != nil {
+				return err
+			}
+			for _, c := range conflicts {
+				log.WithFields(log.Fields{
+					"stroke": c.Brief,
+					"base":   c.Base,
+					"ours":   c.A,
+					"theirs": c.B,
+				}).Error("changed on both sides")
+			}
+			if len(conflicts) > 0 {
+				return fmt.Errorf("%d strokes were changed on both sides; kept our translations", len(conflicts))
+			}
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func newTextconvCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "textconv file.json",
+		Args:  cobra.ExactArgs(1),
+		Short: "Prints a progress file or dictionary as sorted JSON, for git diffs",
+		Long: `Prints a Typey Type progress file or Plover dictionary as sorted,
+indented JSON with one entry per line, so that git diffs show only the entries
+that changed.
+
+` + gitSetupHelp,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, err := jsonKind(args[0])
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			switch kind {
+			case progressKind:
+				progress, err := readProgressOrEmpty(args[0])
+				if err != nil {
+					return err
+				}
+				return typeyprogress.Write(out, progress)
+			default:
+				system, err := readSystem()
+				if err != nil {
+					return err
+				}
+				d, err := readDictionaryOrEmpty(args[0], system)
+				if err != nil {
+					return err
+				}
+				b, err := d.MarshalJSON()
+				if err != nil {
+					return err
+				}
+				_, err = out.Write(b)
+				return err
+			}
+		},
+	}
+
+	return cmd
+}
+
+const (
+	progressKind   = "progress"
+	dictionaryKind = "dictionary"
+)
+
+// jsonKind returns whether the given JSON files hold a progress file (words to
+// counts) or a dictionary (strokes to translations). The first file with any
+// entries decides; if none have any, they are taken to be dictionaries.
+func jsonKind(filenames ...string) (string, error) {
+	for _, filename := range filenames {
+		inBytes, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", err
+		}
+		if len(strings.TrimSpace(string(inBytes))) == 0 {
+			continue
+		}
+		var entries map[string]interface{}
+		if err := json.Unmarshal(inBytes, &entries); err != nil {
+			return "", fmt.Errorf("%s: %v", filename, err)
+		}
+		for _, v := range entries {
+			switch v.(type) {
+			case float64:
+				return progressKind, nil
+			case string:
+				return dictionaryKind, nil
+			}
+			return "", fmt.Errorf("%s is neither a progress file nor a dictionary", filename)
+		}
+	}
+	return dictionaryKind, nil
+}
+
+// readProgressOrEmpty reads a progress file. An empty file (as git gives for
+// a missing ancestor) is read as no progress.
+func readProgressOrEmpty(filename string) (map[string]int, error) {
+	if info, err := os.Stat(filename); err == nil && info.Size() == 0 {
+		return map[string]int{}, nil
+	}
+	return typeyprogress.ReadFile(filename)
+}
+
+// readDictionaryOrEmpty reads a JSON dictionary. An empty file (as git gives
+// for a missing ancestor) is read as an empty dictionary.
+func readDictionaryOrEmpty(filename string, system *dictionary.System) (*dictionary.Dictionary, error) {
+	if info, err := os.Stat(filename); err == nil && info.Size() == 0 {
+		return dictionary.NewSystemDictionary(system), nil
+	}
+	return dictionary.ReadSystemFile(filename, system)
 }
@@ -0,0 +1,102 @@
+// Package orthography joins English words and suffixes the way Plover does
+// when it attaches a suffix like `{^ing}` to the word before it.
+package orthography
+
+import (
+	"strings"
+)
+
+// AddSuffix joins the given word and suffix, applying the English spelling
+// rules for dropping a silent e, doubling a final consonant, changing y to i,
+// and so on.
+func AddSuffix(word, suffix string) string {
+	if word == "" || suffix == "" {
+		return word + suffix
+	}
+	lowerWord := strings.ToLower(word)
+	lowerSuffix := strings.ToLower(suffix)
+	last := lowerWord[len(lowerWord)-1]
+	first := lowerSuffix[0]
+
+	switch {
+	// plurals and third person: box + s = boxes, church + s = churches
+	case lowerSuffix == "s" && hasAnySuffix(lowerWord, "s", "x", "z", "ch", "sh"):
+		return word + "es"
+
+	// consonant + y: cry + s = cries, happy + ness = happiness (but cry + ing
+	// = crying)
+	case last == 'y' && len(lowerWord) > 1 && !isVowel(lowerWord[len(lowerWord)-2]):
+		switch {
+		case first == 'i':
+			return word + suffix
+		case lowerSuffix == "s":
+			return word[:len(word)-1] + "ies"
+		}
+		return word[:len(word)-1] + "i" + suffix
+
+	// ie + ing: die + ing = dying
+	case strings.HasSuffix(lowerWord, "ie") && lowerSuffix == "ing":
+		return word[:len(word)-2] + "y" + suffix
+
+	// consonant + le + ly: simple + ly = simply
+	case strings.HasSuffix(lowerWord, "le") && lowerSuffix == "ly" && len(lowerWord) > 2 && !isVowel(lowerWord[len(lowerWord)-3]):
+		return word[:len(word)-1] + "y"
+
+	// silent e before a vowel: make + ing = making, move + ed = moved (but
+	// see + ing = seeing, and see + ed = seed)
+	case last == 'e' && isVowel(first):
+		if len(lowerWord) > 1 && strings.IndexByte("eoy", lowerWord[len(lowerWord)-2]) >= 0 {
+			if lowerSuffix == "ed" || lowerSuffix == "er" {
+				return word + suffix[1:]
+			}
+			return word + suffix
+		}
+		return word[:len(word)-1] + suffix
+
+	// doubled consonant: stop + ing = stopping, run + er = runner
+	case isVowel(first) && shouldDouble(lowerWord):
+		return word + word[len(word)-1:] + suffix
+	}
+
+	return word + suffix
+}
+
+func hasAnySuffix(s string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("aeiou", c) >= 0
+}
+
+// shouldDouble reports whether the final consonant of the given (lowercase)
+// word doubles before a vowel suffix. Without stress information this only
+// applies to words with a single vowel group that end consonant-vowel-
+// consonant, which covers the one-syllable words drills are made of.
+func shouldDouble(word string) bool {
+	n := len(word)
+	if n < 3 {
+		return false
+	}
+	c1, v, c2 := word[n-3], word[n-2], word[n-1]
+	// qu acts as a consonant: quit + ing = quitting
+	quConsonant := c1 == 'u' && n > 3 && word[n-4] == 'q'
+	if (isVowel(c1) && !quConsonant) || !isVowel(v) || isVowel(c2) || strings.IndexByte("wxy", c2) >= 0 {
+		return false
+	}
+	vowelGroups := 0
+	inVowel := false
+	for i := 0; i < n; i++ {
+		vowel := isVowel(word[i]) && !(word[i] == 'u' && i > 0 && word[i-1] == 'q')
+		if vowel && !inVowel {
+			vowelGroups++
+		}
+		inVowel = vowel
+	}
+	return vowelGroups == 1
+}
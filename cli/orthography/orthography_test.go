@@ -0,0 +1,44 @@
+package orthography
+
+import "testing"
+
+func TestAddSuffix(t *testing.T) {
+	cases := []struct {
+		word     string
+		suffix   string
+		expected string
+	}{
+		{"test", "ing", "testing"},
+		{"test", "ed", "tested"},
+		{"test", "s", "tests"},
+		{"make", "ing", "making"},
+		{"move", "ed", "moved"},
+		{"argue", "ing", "arguing"},
+		{"see", "ing", "seeing"},
+		{"free", "ed", "freed"},
+		{"stop", "ing", "stopping"},
+		{"run", "er", "runner"},
+		{"skip", "ed", "skipped"},
+		{"quit", "ing", "quitting"},
+		{"visit", "ing", "visiting"},
+		{"beat", "ing", "beating"},
+		{"fix", "ing", "fixing"},
+		{"play", "ed", "played"},
+		{"cry", "ed", "cried"},
+		{"cry", "ing", "crying"},
+		{"cry", "s", "cries"},
+		{"happy", "ness", "happiness"},
+		{"die", "ing", "dying"},
+		{"box", "s", "boxes"},
+		{"church", "s", "churches"},
+		{"simple", "ly", "simply"},
+		{"Stop", "ing", "Stopping"},
+	}
+	for _, c := range cases {
+		t.Run(c.word+"+"+c.suffix, func(t *testing.T) {
+			if actual := AddSuffix(c.word, c.suffix); actual != c.expected {
+				t.Errorf("expected %s, got %s", c.expected, actual)
+			}
+		})
+	}
+}
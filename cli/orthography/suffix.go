@@ -0,0 +1,84 @@
+package orthography
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spilliams/steno/cli/dictionary"
+)
+
+// SuffixKey is a steno key that can be folded into a stroke to add a suffix to
+// the word the rest of the stroke translates to (e.g. TEFTD for "tested").
+type SuffixKey struct {
+	Key    dictionary.Keymask
+	Suffix string
+}
+
+type suffixKeyJSON struct {
+	Key    string `json:"key"`
+	Suffix string `json:"suffix"`
+}
+
+// DefaultSuffixKeys returns the suffix keys Plover's English system folds, in
+// the order it tries them, leaving out any that the given system doesn't have.
+func DefaultSuffixKeys(system *dictionary.System) []SuffixKey {
+	defaults := []suffixKeyJSON{
+		{Key: "-Z", Suffix: "s"},
+		{Key: "-D", Suffix: "ed"},
+		{Key: "-S", Suffix: "s"},
+		{Key: "-G", Suffix: "ing"},
+	}
+	keys := make([]SuffixKey, 0, len(defaults))
+	for _, d := range defaults {
+		if key, err := system.Key(d.Key); err == nil {
+			keys = append(keys, SuffixKey{Key: key, Suffix: d.Suffix})
+		}
+	}
+	return keys
+}
+
+// ReadSuffixKeysFile reads a list of suffix keys from a JSON file, e.g.
+// `[{"key": "-G", "suffix": "ing"}]`, parsing the keys with the given system.
+// Keys are tried in the order they are listed.
+func ReadSuffixKeysFile(filename string, system *dictionary.System) ([]SuffixKey, error) {
+	inBytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var in []suffixKeyJSON
+	if err = json.Unmarshal(inBytes, &in); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	keys := make([]SuffixKey, len(in))
+	for i, k := range in {
+		key, err := system.ParseStroke(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		if k.Suffix == "" {
+			return nil, fmt.Errorf("%s: suffix key %s has no suffix", filename, k.Key)
+		}
+		keys[i] = SuffixKey{Key: key, Suffix: k.Suffix}
+	}
+	return keys, nil
+}
+
+// Fold is one way of splitting a stroke into a stem and a folded suffix key
+type Fold struct {
+	Stem   dictionary.Keymask
+	Suffix string
+}
+
+// Folds returns every way the given stroke can be split into a stem and one of
+// the given suffix keys, in the order of the keys.
+func Folds(stroke dictionary.Keymask, keys []SuffixKey) []Fold {
+	folds := make([]Fold, 0)
+	for _, k := range keys {
+		if stroke&k.Key != k.Key || stroke == k.Key {
+			continue
+		}
+		folds = append(folds, Fold{Stem: stroke &^ k.Key, Suffix: k.Suffix})
+	}
+	return folds
+}
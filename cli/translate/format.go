@@ -0,0 +1,213 @@
+package translate
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/apex/log"
+	"github.com/spilliams/steno/cli/dictionary"
+	"github.com/spilliams/steno/cli/orthography"
+)
+
+// Output is the result of formatting a series of translations: the text that
+// would be typed, and the key combos that would be sent alongside it.
+type Output struct {
+	Text      string     `json:"text"`
+	KeyCombos []KeyCombo `json:"keyCombos"`
+}
+
+// KeyCombo is a `{#...}` key combo, and the position in the output text at
+// which it was sent.
+type KeyCombo struct {
+	Combo  string `json:"combo"`
+	Offset int    `json:"offset"`
+}
+
+type caseMode int
+
+const (
+	caseNone caseMode = iota
+	caseCapitalize
+	caseLower
+	caseUpper
+)
+
+// formatter applies Plover's formatting operators to translations, in order
+type formatter struct {
+	text *strings.Builder
+	// tail is the text after the last whitespace, which a suffix may still
+	// change
+	tail      string
+	keyCombos []KeyCombo
+	// numberKey is the system's number key, which marks untranslated number
+	// strokes
+	numberKey dictionary.Keymask
+	// attachNext suppresses the space before the next piece of text
+	attachNext bool
+	// glued is true if the last piece of text was glued with `{&...}`
+	glued    bool
+	nextCase caseMode
+}
+
+func newFormatter(system *dictionary.System) *formatter {
+	return &formatter{
+		text:      new(strings.Builder),
+		keyCombos: make([]KeyCombo, 0),
+		numberKey: system.NumberKey(),
+		// nothing goes before the first word
+		attachNext: true,
+	}
+}
+
+func (f *formatter) output() *Output {
+	return &Output{
+		Text:      f.text.String() + f.tail,
+		KeyCombos: f.keyCombos,
+	}
+}
+
+func (f *formatter) format(t *Translation) {
+	if t.Untranslated {
+		f.formatUntranslated(t)
+		return
+	}
+	parts, err := dictionary.ParseTranslation(t.English)
+	if err != nil {
+		log.WithError(err).WithField("translation", t.English).Warn("translation could not be parsed, so it will be typed as-is")
+		f.word(t.English, false, false)
+		return
+	}
+	for _, p := range parts {
+		if p.IsOperator {
+			f.operator(p.Text)
+			continue
+		}
+		f.word(p.Text, false, false)
+	}
+}
+
+// formatUntranslated types the raw stroke. Strokes made up only of numbers are
+// glued together, the way Plover does.
+func (f *formatter) formatUntranslated(t *Translation) {
+	digits := strings.Replace(t.English, "-", "", -1)
+	number := f.numberKey != 0 && t.Strokes[0]&f.numberKey == f.numberKey
+	if number && digits != "" && strings.Trim(digits, "0123456789") == "" {
+		f.glue(digits)
+		return
+	}
+	f.word(t.English, false, false)
+}
+
+// word writes a piece of text. If attachBefore is set it is attached to the
+// previous text; if attachAfter is set the next text will be attached to it.
+func (f *formatter) word(s string, attachBefore, attachAfter bool) {
+	if s == "" {
+		if attachAfter {
+			f.attachNext = true
+		}
+		return
+	}
+	if !attachBefore && !f.attachNext {
+		f.write(" ")
+	}
+	f.write(f.applyCase(s))
+	f.attachNext = attachAfter
+	f.glued = false
+}
+
+// write appends s to the output text, moving everything up to the last
+// whitespace out of the tail
+func (f *formatter) write(s string) {
+	f.tail += s
+	if i := strings.LastIndexAny(f.tail, " \n\t"); i >= 0 {
+		f.text.WriteString(f.tail[:i+1])
+		f.tail = f.tail[i+1:]
+	}
+}
+
+// suffix attaches a suffix that starts with a letter to the last word of the
+// output, applying English orthography (e.g. "make" + "ing" = "making"). It
+// returns false if there is no word to attach to.
+func (f *formatter) suffix(s string, attachAfter bool) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	if !unicode.IsLetter(r) {
+		return false
+	}
+	last, _ := utf8.DecodeLastRuneInString(f.tail)
+	if !unicode.IsLetter(last) {
+		return false
+	}
+	word := orthography.AddSuffix(f.tail, f.applyCase(s))
+	f.tail = ""
+	f.write(word)
+	f.attachNext = attachAfter
+	f.glued = false
+	return true
+}
+
+func (f *formatter) applyCase(s string) string {
+	mode := f.nextCase
+	f.nextCase = caseNone
+	switch mode {
+	case caseCapitalize:
+		r, size := utf8.DecodeRuneInString(s)
+		return string(unicode.ToUpper(r)) + s[size:]
+	case caseLower:
+		r, size := utf8.DecodeRuneInString(s)
+		return string(unicode.ToLower(r)) + s[size:]
+	case caseUpper:
+		return strings.ToUpper(s)
+	}
+	return s
+}
+
+func (f *formatter) glue(s string) {
+	attach := f.glued
+	f.word(s, attach, false)
+	f.glued = true
+}
+
+// operator applies a single formatting operator (without its braces)
+func (f *formatter) operator(op string) {
+	switch op {
+	case "", "^", "^^":
+		f.attachNext = true
+		return
+	case "-|":
+		f.nextCase = caseCapitalize
+		return
+	case ">":
+		f.nextCase = caseLower
+		return
+	case "<":
+		f.nextCase = caseUpper
+		return
+	case ".", "?", "!":
+		f.word(op, true, false)
+		f.nextCase = caseCapitalize
+		return
+	case ",", ":", ";":
+		f.word(op, true, false)
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(op, "#"):
+		for _, combo := range strings.Fields(op[1:]) {
+			f.keyCombos = append(f.keyCombos, KeyCombo{Combo: combo, Offset: f.text.Len() + len(f.tail)})
+		}
+	case strings.HasPrefix(op, "&"):
+		f.glue(op[1:])
+	case strings.HasPrefix(op, "^") || strings.HasSuffix(op, "^"):
+		attachBefore := strings.HasPrefix(op, "^")
+		attachAfter := strings.HasSuffix(op, "^")
+		text := strings.TrimSuffix(strings.TrimPrefix(op, "^"), "^")
+		if attachBefore && f.suffix(text, attachAfter) {
+			return
+		}
+		f.word(text, attachBefore, attachAfter)
+	default:
+		log.WithField("operator", op).Debug("unsupported operator ignored")
+	}
+}
@@ -0,0 +1,158 @@
+// Package translate turns a stream of steno strokes into the text Plover would
+// type for them, given a stack of dictionaries.
+package translate
+
+import (
+	"github.com/spilliams/steno/cli/dictionary"
+	"github.com/spilliams/steno/cli/orthography"
+)
+
+// undoMacro is the translation Plover's main dictionary gives to `*`
+const undoMacro = "=undo"
+
+// Translation is one or more consecutive strokes, and what they translate to.
+type Translation struct {
+	Strokes []dictionary.Keymask
+	// English is the translation from the dictionary, or the raw stroke if the
+	// strokes were untranslated
+	English string
+	// Source is the name of the dictionary the translation came from
+	Source       string
+	Untranslated bool
+	// replaced holds the translations that this one absorbed, so they can be
+	// restored if this one is undone
+	replaced []*Translation
+}
+
+// Translator keeps the history of translations made from the strokes it has
+// been given.
+type Translator struct {
+	stack        *dictionary.Stack
+	system       *dictionary.System
+	longest      int
+	suffixKeys   []orthography.SuffixKey
+	translations []*Translation
+}
+
+// NewTranslator returns a translator that looks strokes up in the given stack.
+// It folds Plover's default suffix keys (see orthography.DefaultSuffixKeys).
+func NewTranslator(s *dictionary.Stack) *Translator {
+	return &Translator{
+		stack:      s,
+		system:     s.System(),
+		longest:    s.LongestBrief(),
+		suffixKeys: orthography.DefaultSuffixKeys(s.System()),
+	}
+}
+
+// SetSuffixKeys changes the suffix keys the receiver tries to fold out of a
+// stroke that is missing from the stack. Pass nil to turn folding off.
+func (t *Translator) SetSuffixKeys(keys []orthography.SuffixKey) {
+	t.suffixKeys = keys
+}
+
+// Translations returns the receiver's current translations, oldest first
+func (t *Translator) Translations() []*Translation {
+	translations := make([]*Translation, len(t.translations))
+	copy(translations, t.translations)
+	return translations
+}
+
+// Translate feeds a stroke to the receiver. The longest run of recent strokes
+// that the stack has an entry for wins, replacing the translations it spans.
+// A stroke that translates to `=undo` (or a lone `*`, if no dictionary
+// defines it) undoes the last translation instead.
+func (t *Translator) Translate(stroke dictionary.Keymask) {
+	if t.isUndo(stroke) {
+		t.undo()
+		return
+	}
+
+	// try to combine the stroke with as many previous translations as
+	// possible, starting from the earliest that could still fit
+	start := len(t.translations)
+	count := 1
+	for start > 0 && count+len(t.translations[start-1].Strokes) <= t.longest {
+		start--
+		count += len(t.translations[start].Strokes)
+	}
+	for i := start; i <= len(t.translations); i++ {
+		strokes := make([]dictionary.Keymask, 0)
+		for _, prior := range t.translations[i:] {
+			strokes = append(strokes, prior.Strokes...)
+		}
+		strokes = append(strokes, stroke)
+
+		english, source, ok := t.lookup(strokes)
+		if !ok {
+			continue
+		}
+		replaced := make([]*Translation, len(t.translations)-i)
+		copy(replaced, t.translations[i:])
+		t.translations = append(t.translations[:i], &Translation{
+			Strokes:  strokes,
+			English:  english,
+			Source:   source,
+			replaced: replaced,
+		})
+		return
+	}
+
+	t.translations = append(t.translations, &Translation{
+		Strokes:      []dictionary.Keymask{stroke},
+		English:      t.system.StrokeString(stroke),
+		Untranslated: true,
+	})
+}
+
+// lookup finds the translation for the given strokes. If they are missing
+// from the stack, it tries folding a suffix key out of the last stroke and
+// attaching the suffix to the translation of what is left.
+func (t *Translator) lookup(strokes []dictionary.Keymask) (string, string, bool) {
+	if english, source, ok := t.stack.Lookup(dictionary.NewBrief(strokes...)); ok {
+		return english, source, true
+	}
+	last := len(strokes) - 1
+	for _, fold := range orthography.Folds(strokes[last], t.suffixKeys) {
+		stem := make([]dictionary.Keymask, len(strokes))
+		copy(stem, strokes)
+		stem[last] = fold.Stem
+		if english, source, ok := t.stack.Lookup(dictionary.NewBrief(stem...)); ok {
+			return english + "{^" + fold.Suffix + "}", source, true
+		}
+	}
+	return "", "", false
+}
+
+func (t *Translator) isUndo(stroke dictionary.Keymask) bool {
+	english, _, ok := t.stack.Lookup(dictionary.SingleStrokeBrief(stroke))
+	if ok {
+		return english == undoMacro
+	}
+	star, err := t.system.Key("*")
+	return err == nil && stroke == star
+}
+
+func (t *Translator) undo() {
+	if len(t.translations) == 0 {
+		return
+	}
+	last := t.translations[len(t.translations)-1]
+	t.translations = append(t.translations[:len(t.translations)-1], last.replaced...)
+}
+
+// TranslateBrief feeds every stroke of the given brief to the receiver
+func (t *Translator) TranslateBrief(b *dictionary.Brief) {
+	for _, stroke := range b.Strokes() {
+		t.Translate(stroke)
+	}
+}
+
+// Output formats the receiver's current translations
+func (t *Translator) Output() *Output {
+	f := newFormatter(t.system)
+	for _, translation := range t.translations {
+		f.format(translation)
+	}
+	return f.output()
+}
@@ -0,0 +1,152 @@
+package translate
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/spilliams/steno/cli/dictionary"
+)
+
+func testStack(t *testing.T) *dictionary.Stack {
+	t.Helper()
+	main := dictionary.NewDictionary()
+	if err := json.Unmarshal([]byte(`{
+		"*": "=undo",
+		"TEFT": "test",
+		"-G": "{^ing}",
+		"TEFT/-G": "testing",
+		"KAT": "cat",
+		"KAT/A*LG": "catalog",
+		"A*LG": "allege",
+		"TP-PL": "{.}",
+		"KW-BG": "{,}",
+		"KP-FPL": "{-|}",
+		"RE": "{re^}",
+		"TKO": "do",
+		"A*": "{&a}",
+		"PW*": "{&b}",
+		"SKP": "and",
+		"KPA*": "{^}{-|}",
+		"PHAEUBG": "make",
+		"STOP": "stop",
+		"SKEUL": "skill",
+		"TKPWOE": "go",
+		"TKPWOEZ": "goes"
+	}`), main); err != nil {
+		t.Fatal(err)
+	}
+	commands := dictionary.NewDictionary()
+	if err := json.Unmarshal([]byte(`{
+		"SHR-FRLG": "{#Return}{^}{-|}",
+		"KPW-FRLG": "{#BackSpace}{^}{>}",
+		"KPW-FRPLG": "{#Control_L(c) Control_L(v)}"
+	}`), commands); err != nil {
+		t.Fatal(err)
+	}
+	s := dictionary.NewStack()
+	s.Push("main", main)
+	s.Push("commands", commands)
+	return s
+}
+
+func translateAll(t *testing.T, s *dictionary.Stack, strokes string) *Output {
+	t.Helper()
+	tr := NewTranslator(s)
+	for _, in := range strings.Fields(strokes) {
+		b, err := dictionary.ParseBrief(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr.TranslateBrief(b)
+	}
+	return tr.Output()
+}
+
+func TestTranslate(t *testing.T) {
+	cases := []struct {
+		name    string
+		strokes string
+		text    string
+		combos  []string
+	}{
+		{"single word", "TEFT", "test", nil},
+		{"two words", "TEFT KAT", "test cat", nil},
+		{"multi-stroke", "TEFT/-G", "testing", nil},
+		{"longest match", "KAT A*LG", "catalog", nil},
+		{"untranslated", "KAT TPHR", "cat TPHR", nil},
+		{"undo", "TEFT KAT *", "test", nil},
+		{"undo a multi-stroke entry", "KAT/A*LG *", "cat", nil},
+		{"undo everything", "TEFT * *", "", nil},
+		{"period", "TEFT TP-PL KAT", "test. Cat", nil},
+		{"comma", "TEFT KW-BG KAT", "test, cat", nil},
+		{"capitalize", "KP-FPL TEFT", "Test", nil},
+		{"prefix", "RE TKO", "redo", nil},
+		{"glue", "A* PW* KAT A*", "ab cat a", nil},
+		{"attach and capitalize", "TEFT KPA* KAT", "testCat", nil},
+		{"numbers are glued", "1-6 2", "162", nil},
+		{"key combo", "TEFT SHR-FRLG KAT", "testCat", []string{"Return"}},
+		{"key combo lowercase", "KP-FPL KPW-FRLG TEFT", "test", []string{"BackSpace"}},
+		{"several key combos", "KPW-FRPLG", "", []string{"Control_L(c)", "Control_L(v)"}},
+		{"suffix orthography", "PHAEUBG -G", "making", nil},
+		{"suffix doubling", "STOP -G", "stopping", nil},
+		{"folded suffix", "STOPD", "stopped", nil},
+		{"folded suffix after a word", "TEFT SKEULS", "test skills", nil},
+		{"exact entry beats folding", "TKPWOEZ", "goes", nil},
+		{"undo a folded suffix", "TEFTD *", "", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out := translateAll(t, testStack(t), c.strokes)
+			if out.Text != c.text {
+				t.Errorf("expected text %q, got %q", c.text, out.Text)
+			}
+			if len(out.KeyCombos) != len(c.combos) {
+				t.Fatalf("expected %d key combos, got %v", len(c.combos), out.KeyCombos)
+			}
+			for i, combo := range c.combos {
+				if out.KeyCombos[i].Combo != combo {
+					t.Errorf("expected %dth key combo to be %s, got %s", i, combo, out.KeyCombos[i].Combo)
+				}
+			}
+		})
+	}
+}
+
+func TestTranslateOtherSystem(t *testing.T) {
+	system, err := dictionary.NewSystem(dictionary.SystemDefinition{
+		Name: "Extended English",
+		Keys: []string{
+			"#", "^-", "+-",
+			"S-", "T-", "K-", "P-", "W-", "H-", "R-",
+			"A-", "O-",
+			"*",
+			"-E", "-U",
+			"-F", "-R", "-P", "-B", "-L", "-G", "-T", "-S", "-D", "-Z",
+		},
+		ImplicitHyphenKeys: []string{"A-", "O-", "*", "-E", "-U"},
+		NumberKey:          "#",
+		Numbers:            map[string]string{"S-": "1-", "T-": "2-"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := dictionary.NewSystemDictionary(system)
+	if err := json.Unmarshal([]byte(`{"TEFT": "test", "^KAT": "cat"}`), d); err != nil {
+		t.Fatal(err)
+	}
+	s := dictionary.NewStack()
+	s.Push("main", d)
+
+	tr := NewTranslator(s)
+	for _, in := range []string{"TEFTD", "^KATS", "1", "2"} {
+		b, err := system.ParseBrief(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr.TranslateBrief(b)
+	}
+	if text := tr.Output().Text; text != "tested cats 12" {
+		t.Errorf("expected suffix keys and numbers to follow the system, got %q", text)
+	}
+}
@@ -0,0 +1,162 @@
+package typeyprogress
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Summary is the size of a progress map
+type Summary struct {
+	Words   int `json:"words"`
+	Strokes int `json:"strokes"`
+}
+
+// Summarize returns the number of words in the given progress, and the total
+// number of times they have been typed correctly
+func Summarize(progress map[string]int) Summary {
+	s := Summary{Words: len(progress)}
+	for _, count := range progress {
+		s.Strokes += count
+	}
+	return s
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%d words chorded correctly %d times", s.Words, s.Strokes)
+}
+
+// CleanOpts are the options for Clean
+type CleanOpts struct {
+	// FoldCase merges words that differ only in case (e.g. "The" and "the")
+	FoldCase bool
+	// KeepPunctuation keeps words made only of punctuation and symbols (like
+	// the ones Typey Type's punctuation lessons record), which are otherwise
+	// dropped
+	KeepPunctuation bool
+}
+
+// MergedWord is a word that more than one key of a progress map cleaned to
+type MergedWord struct {
+	Word  string      `json:"word"`
+	Count int         `json:"count"`
+	Keys  []WordCount `json:"keys"`
+}
+
+// CleanReport is what Clean did to a progress map
+type CleanReport struct {
+	// Before and After summarize the progress before and after cleaning
+	Before Summary `json:"before"`
+	After  Summary `json:"after"`
+	// Merged are the words that more than one key cleaned to, in
+	// alphabetical order
+	Merged []MergedWord `json:"merged"`
+	// Dropped are the keys that were empty or only punctuation, in
+	// alphabetical order
+	Dropped []WordCount `json:"dropped"`
+}
+
+// Clean cleans up the keys of the given progress map: HTML entities (like
+// &lt;) and JSON escapes written out in full (like \u003c) are decoded,
+// Unicode is normalized to NFC, surrounding whitespace is trimmed, and (if
+// asked) case is folded. Keys that are left empty, or with nothing but
+// punctuation, are dropped. Keys that clean to the same word are merged,
+// adding their counts together.
+func Clean(a map[string]int, opts CleanOpts) (map[string]int, CleanReport) {
+	report := CleanReport{
+		Before:  Summarize(a),
+		Merged:  make([]MergedWord, 0),
+		Dropped: make([]WordCount, 0),
+	}
+	cleaned := make(map[string]int, len(a))
+	keys := make(map[string][]WordCount)
+	for k, v := range a {
+		word := cleanWord(k, opts)
+		if word == "" || (!opts.KeepPunctuation && isPunctuation(word)) {
+			report.Dropped = append(report.Dropped, WordCount{k, v})
+			continue
+		}
+		cleaned[word] += v
+		keys[word] = append(keys[word], WordCount{k, v})
+	}
+
+	for word, from := range keys {
+		if len(from) < 2 {
+			continue
+		}
+		sortWordCounts(from)
+		report.Merged = append(report.Merged, MergedWord{word, cleaned[word], from})
+	}
+	sort.Slice(report.Merged, func(i, j int) bool {
+		return report.Merged[i].Word < report.Merged[j].Word
+	})
+	sortWordCounts(report.Dropped)
+	report.After = Summarize(cleaned)
+	return cleaned, report
+}
+
+// jsonEscapes are the escapes Go's JSON encoder writes for HTML characters,
+// which can end up in keys when a progress file is escaped twice
+var jsonEscapes = strings.NewReplacer(`\u003c`, "<", `\u003e`, ">", `\u0026`, "&")
+
+// foldCase folds case for Clean
+var foldCase = cases.Fold()
+
+func cleanWord(word string, opts CleanOpts) string {
+	word = jsonEscapes.Replace(html.UnescapeString(word))
+	word = strings.TrimSpace(norm.NFC.String(word))
+	if opts.FoldCase {
+		word = foldCase.String(word)
+	}
+	return word
+}
+
+// isPunctuation returns true if the given word is made only of punctuation
+// and symbols
+func isPunctuation(word string) bool {
+	for _, r := range word {
+		if !unicode.IsPunct(r) && !unicode.IsSymbol(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortWordCounts(counts []WordCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		return counts[i].Word < counts[j].Word
+	})
+}
+
+// WriteCleanReportText writes the given report to w: the summaries before
+// and after, then the merged and dropped keys
+func WriteCleanReportText(w io.Writer, r CleanReport) error {
+	lines := []string{
+		fmt.Sprintf("before: %s", r.Before),
+		fmt.Sprintf("after: %s", r.After),
+		fmt.Sprintf("merged (%d):", len(r.Merged)),
+	}
+	for _, m := range r.Merged {
+		from := make([]string, len(m.Keys))
+		for i, k := range m.Keys {
+			from[i] = fmt.Sprintf("%q (%d)", k.Word, k.Count)
+		}
+		lines = append(lines, fmt.Sprintf("  %s\t%d\tfrom %s", m.Word, m.Count, strings.Join(from, ", ")))
+	}
+	lines = append(lines, fmt.Sprintf("dropped (%d):", len(r.Dropped)))
+	for _, d := range r.Dropped {
+		lines = append(lines, fmt.Sprintf("  %q\t%d", d.Word, d.Count))
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
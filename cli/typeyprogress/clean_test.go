@@ -0,0 +1,54 @@
+package typeyprogress
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClean(t *testing.T) {
+	progress := map[string]int{
+		"the":             10,
+		" the ":           2,
+		"The":             1,
+		"&lt;div&gt;":     3,
+		`\u003cdiv\u003e`: 1,
+		"café":           4,
+		"café":            1,
+		"":                5,
+		"  ":              1,
+		"!":               2,
+		"rock &amp; roll": 1,
+	}
+
+	cleaned, report := Clean(progress, CleanOpts{})
+	expected := map[string]int{
+		"the":         12,
+		"The":         1,
+		"<div>":       4,
+		"café":        5,
+		"rock & roll": 1,
+	}
+	if !reflect.DeepEqual(cleaned, expected) {
+		t.Errorf("expected %v, got %v", expected, cleaned)
+	}
+	if report.Before != (Summary{11, 31}) || report.After != (Summary{5, 23}) {
+		t.Errorf("expected 11 words typed 31 times to clean to 5 typed 23 times, got %v and %v", report.Before, report.After)
+	}
+	expectedMerged := []MergedWord{
+		{"<div>", 4, []WordCount{{"&lt;div&gt;", 3}, {`\u003cdiv\u003e`, 1}}},
+		{"café", 5, []WordCount{{"café", 4}, {"café", 1}}},
+		{"the", 12, []WordCount{{" the ", 2}, {"the", 10}}},
+	}
+	if !reflect.DeepEqual(report.Merged, expectedMerged) {
+		t.Errorf("expected merged %v, got %v", expectedMerged, report.Merged)
+	}
+	expectedDropped := []WordCount{{"", 5}, {"  ", 1}, {"!", 2}}
+	if !reflect.DeepEqual(report.Dropped, expectedDropped) {
+		t.Errorf("expected dropped %v, got %v", expectedDropped, report.Dropped)
+	}
+
+	cleaned, _ = Clean(progress, CleanOpts{FoldCase: true, KeepPunctuation: true})
+	if cleaned["the"] != 13 || cleaned["!"] != 2 {
+		t.Errorf("expected case to be folded and punctuation kept, got %v", cleaned)
+	}
+}
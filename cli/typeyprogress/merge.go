@@ -0,0 +1,115 @@
+package typeyprogress
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// MergeStrategy decides the count of a word that is in both of the progress
+// files being merged
+type MergeStrategy string
+
+const (
+	// MergeSum adds the two counts together. Use it for files that have
+	// nothing in common, like progress made on two machines from scratch.
+	MergeSum MergeStrategy = "sum"
+	// MergeMax takes the larger count, so merging a file with itself (or
+	// with an older copy of itself) changes nothing
+	MergeMax MergeStrategy = "max"
+	// MergePreferNewer takes the count from the newer file
+	MergePreferNewer MergeStrategy = "prefer-newer"
+)
+
+// ParseMergeStrategy returns the merge strategy with the given name
+func ParseMergeStrategy(name string) (MergeStrategy, error) {
+	switch s := MergeStrategy(name); s {
+	case MergeSum, MergeMax, MergePreferNewer:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown merge strategy %s (expected sum, max or prefer-newer)", name)
+}
+
+// MergeWith merges two progress maps with the given strategy. Words in only
+// one of them are kept as they are. For MergePreferNewer, b is the newer.
+func MergeWith(a, b map[string]int, strategy MergeStrategy) map[string]int {
+	c := make(map[string]int, len(a))
+	for k, v := range a {
+		c[k] = v
+	}
+	for k, v := range b {
+		prior, ok := c[k]
+		if !ok {
+			c[k] = v
+			continue
+		}
+		switch strategy {
+		case MergeSum:
+			c[k] = prior + v
+		case MergeMax:
+			if v > prior {
+				c[k] = v
+			}
+		case MergePreferNewer:
+			c[k] = v
+		}
+	}
+	return c
+}
+
+// Change is a word whose count changed on both sides of a three-way merge
+type Change struct {
+	Word   string `json:"word"`
+	Base   int    `json:"base"`
+	A      int    `json:"a"`
+	B      int    `json:"b"`
+	Merged int    `json:"merged"`
+}
+
+// Merge3 merges two progress maps that were both copied from a common
+// ancestor, base. Each word gets the base count plus the change each side
+// made to it, so progress made on both sides is counted once. A word that is
+// missing from a map counts as 0, and a word that ends up with no count (e.g.
+// because one side removed it and the other didn't touch it) is left out.
+//
+// It also returns the words that changed on both sides, in alphabetical
+// order.
+func Merge3(base, a, b map[string]int) (map[string]int, []Change) {
+	words := make(map[string]bool, len(base))
+	for _, m := range []map[string]int{base, a, b} {
+		for k := range m {
+			words[k] = true
+		}
+	}
+
+	c := make(map[string]int, len(words))
+	changes := make([]Change, 0)
+	for word := range words {
+		ancestor, ok := base[word]
+		aCount, aOK := a[word]
+		bCount, bOK := b[word]
+		merged := aCount + bCount - ancestor
+		if merged > 0 {
+			c[word] = merged
+		} else {
+			merged = 0
+		}
+		if (aCount != ancestor || aOK != ok) && (bCount != ancestor || bOK != ok) {
+			changes = append(changes, Change{word, ancestor, aCount, bCount, merged})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Word < changes[j].Word
+	})
+	return c, changes
+}
+
+// WriteChangesText writes the given changes to w, one per line
+func WriteChangesText(w io.Writer, changes []Change) error {
+	for _, c := range changes {
+		if _, err := fmt.Fprintf(w, "%s\tbase %d\ta %d\tb %d\tmerged %d\n", c.Word, c.Base, c.A, c.B, c.Merged); err != nil {
+			return err
+		}
+	}
+	return nil
+}
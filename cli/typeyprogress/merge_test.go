@@ -0,0 +1,50 @@
+package typeyprogress
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeWith(t *testing.T) {
+	a := map[string]int{"the": 10, "of": 5}
+	b := map[string]int{"the": 4, "and": 2}
+	cases := map[MergeStrategy]map[string]int{
+		MergeSum:         {"the": 14, "of": 5, "and": 2},
+		MergeMax:         {"the": 10, "of": 5, "and": 2},
+		MergePreferNewer: {"the": 4, "of": 5, "and": 2},
+	}
+	for strategy, expected := range cases {
+		if c := MergeWith(a, b, strategy); !reflect.DeepEqual(c, expected) {
+			t.Errorf("expected %s to give %v, got %v", strategy, expected, c)
+		}
+	}
+	if c := MergeWith(a, a, MergeMax); !reflect.DeepEqual(c, a) {
+		t.Errorf("expected merging a file with itself to change nothing, got %v", c)
+	}
+	if _, err := ParseMergeStrategy("min"); err == nil {
+		t.Errorf("expected an unknown strategy to be an error")
+	}
+}
+
+func TestMerge3(t *testing.T) {
+	base := map[string]int{"the": 10, "of": 5, "cat": 2, "dog": 1}
+	a := map[string]int{"the": 15, "of": 5, "cat": 2, "and": 3}
+	b := map[string]int{"the": 12, "of": 8, "cat": 2, "dog": 1, "and": 1}
+
+	c, changes := Merge3(base, a, b)
+	expected := map[string]int{"the": 17, "of": 8, "cat": 2, "and": 4}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected %v, got %v", expected, c)
+	}
+	expectedChanges := []Change{
+		{Word: "and", Base: 0, A: 3, B: 1, Merged: 4},
+		{Word: "the", Base: 10, A: 15, B: 12, Merged: 17},
+	}
+	if !reflect.DeepEqual(changes, expectedChanges) {
+		t.Errorf("expected changes %v, got %v", expectedChanges, changes)
+	}
+
+	if c, changes := Merge3(base, base, base); !reflect.DeepEqual(c, base) || len(changes) != 0 {
+		t.Errorf("expected merging unchanged copies to change nothing, got %v %v", c, changes)
+	}
+}
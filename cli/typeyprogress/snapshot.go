@@ -0,0 +1,109 @@
+package typeyprogress
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotLayout is the time format of snapshot file names, in UTC
+const snapshotLayout = "20060102T150405Z"
+
+// Snapshot is a progress file saved in a Store at a point in time
+type Snapshot struct {
+	Time     time.Time `json:"time"`
+	Filename string    `json:"filename"`
+	// Words is the number of words in the snapshot
+	Words int `json:"words"`
+	// Strokes is the total number of times they have been typed correctly
+	Strokes int `json:"strokes"`
+	// Progress is the map of words to counts
+	Progress map[string]int `json:"-"`
+}
+
+func newSnapshot(t time.Time, filename string, progress map[string]int) Snapshot {
+	s := Snapshot{Time: t, Filename: filename, Words: len(progress), Progress: progress}
+	for _, count := range progress {
+		s.Strokes += count
+	}
+	return s
+}
+
+// Store is a directory of progress snapshots, one file per snapshot, named by
+// the time it was taken (e.g. 20201018T150405Z.json). Files are never changed
+// once written.
+type Store struct {
+	dir string
+}
+
+// NewStore returns a store in the given directory. The directory is created
+// when the first snapshot is saved.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Snapshots returns every snapshot in the receiver, oldest first. Files that
+// aren't named like snapshots are ignored.
+func (s *Store) Snapshots() ([]Snapshot, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]Snapshot, 0, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+		t, err := time.Parse(snapshotLayout, strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		filename := filepath.Join(s.dir, name)
+		progress, err := ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		snapshots = append(snapshots, newSnapshot(t, filename, progress))
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
+
+// Save writes the given progress to the receiver as a snapshot taken at the
+// given time. If it is the same as the latest snapshot, nothing is written,
+// and the latest snapshot is returned with false.
+func (s *Store) Save(progress map[string]int, t time.Time) (Snapshot, bool, error) {
+	snapshots, err := s.Snapshots()
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+	t = t.UTC().Truncate(time.Second)
+	if len(snapshots) > 0 {
+		latest := snapshots[len(snapshots)-1]
+		if reflect.DeepEqual(latest.Progress, progress) {
+			return latest, false, nil
+		}
+		if !t.After(latest.Time) {
+			return Snapshot{}, false, fmt.Errorf("snapshot time %s is not after the latest snapshot (%s)", t.Format(time.RFC3339), latest.Time.Format(time.RFC3339))
+		}
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return Snapshot{}, false, err
+	}
+	filename := filepath.Join(s.dir, t.Format(snapshotLayout)+".json")
+	if err := WriteFile(progress, filename); err != nil {
+		return Snapshot{}, false, err
+	}
+	return newSnapshot(t, filename, progress), true, nil
+}
@@ -0,0 +1,270 @@
+package typeyprogress
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Stage is how well a word is known, going by how many times it has been
+// typed correctly
+type Stage string
+
+const (
+	// StageUnseen words have not been typed correctly yet
+	StageUnseen Stage = "unseen"
+	// StageSeen words have been typed correctly at least Thresholds.Seen times
+	StageSeen Stage = "seen"
+	// StageMemorised words have been typed correctly at least
+	// Thresholds.Memorised times
+	StageMemorised Stage = "memorised"
+	// StageLearned words have been typed correctly at least
+	// Thresholds.Learned times
+	StageLearned Stage = "learned"
+)
+
+// Thresholds are the counts a word must reach to be in each stage
+type Thresholds struct {
+	Seen      int `json:"seen"`
+	Memorised int `json:"memorised"`
+	Learned   int `json:"learned"`
+}
+
+// DefaultThresholds match Typey Type, which counts a word as seen once it is
+// typed and memorised once it is typed 30 times. Learned is a further stage
+// for words that are well past memorised.
+var DefaultThresholds = Thresholds{
+	Seen:      1,
+	Memorised: 30,
+	Learned:   100,
+}
+
+// Validate returns an error if the receiver's thresholds are not positive and
+// in increasing order
+func (t Thresholds) Validate() error {
+	if t.Seen < 1 || t.Memorised <= t.Seen || t.Learned <= t.Memorised {
+		return fmt.Errorf("thresholds must be positive and increasing (seen %d, memorised %d, learned %d)", t.Seen, t.Memorised, t.Learned)
+	}
+	return nil
+}
+
+// Stage returns the stage of a word typed the given number of times
+func (t Thresholds) Stage(count int) Stage {
+	switch {
+	case count >= t.Learned:
+		return StageLearned
+	case count >= t.Memorised:
+		return StageMemorised
+	case count >= t.Seen:
+		return StageSeen
+	}
+	return StageUnseen
+}
+
+// WordCount is a word and the number of times it has been typed correctly
+type WordCount struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+// Bucket is a range of counts, and the number of words whose count falls in
+// it. Max is less than Min for the last, open-ended bucket.
+type Bucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Words int `json:"words"`
+}
+
+// Label returns the receiver's range as a string (e.g. "2-4" or "100+")
+func (b Bucket) Label() string {
+	switch {
+	case b.Max < b.Min:
+		return fmt.Sprintf("%d+", b.Min)
+	case b.Max == b.Min:
+		return fmt.Sprint(b.Min)
+	}
+	return fmt.Sprintf("%d-%d", b.Min, b.Max)
+}
+
+// bucketEdges are the smallest counts of each bucket in a distribution
+var bucketEdges = []int{0, 1, 2, 5, 10, 20, 30, 50, 100, 200, 500, 1000}
+
+// Stats sum up a set of words in a progress file
+type Stats struct {
+	// Name is the name of the word list the stats are for, if any
+	Name string `json:"name,omitempty"`
+	// Words is the number of words counted
+	Words int `json:"words"`
+	// Strokes is the total number of times they have been typed correctly
+	Strokes int `json:"strokes"`
+	// Stages is the number of words in each stage
+	Stages map[Stage]int `json:"stages"`
+	// Distribution is the number of words in each range of counts, up to the
+	// highest count
+	Distribution []Bucket `json:"distribution"`
+	// Top are the most typed words, most first
+	Top []WordCount `json:"top"`
+	// Bottom are the least typed words, least first
+	Bottom []WordCount `json:"bottom"`
+}
+
+// NewStats sums up the given progress. If words is nil, every word in the
+// progress is counted; otherwise only the given words are, and those that are
+// not in the progress count as unseen. Top and Bottom hold up to n words
+// each (none if n is negative).
+func NewStats(progress map[string]int, words []string, t Thresholds, n int) Stats {
+	counts := make([]WordCount, 0)
+	if words == nil {
+		for word, count := range progress {
+			counts = append(counts, WordCount{word, count})
+		}
+	} else {
+		counted := make(map[string]bool, len(words))
+		for _, word := range words {
+			if counted[word] {
+				continue
+			}
+			counted[word] = true
+			counts = append(counts, WordCount{word, progress[word]})
+		}
+	}
+	// most typed first, then alphabetically, so that the order is stable
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Word < counts[j].Word
+	})
+
+	s := Stats{
+		Words: len(counts),
+		Stages: map[Stage]int{
+			StageUnseen:    0,
+			StageSeen:      0,
+			StageMemorised: 0,
+			StageLearned:   0,
+		},
+		Distribution: make([]Bucket, 0),
+	}
+	for _, c := range counts {
+		s.Strokes += c.Count
+		s.Stages[t.Stage(c.Count)]++
+	}
+	if len(counts) > 0 {
+		s.Distribution = distribution(counts)
+	}
+
+	if n < 0 {
+		n = 0
+	}
+	if n > len(counts) {
+		n = len(counts)
+	}
+	s.Top = append([]WordCount{}, counts[:n]...)
+	s.Bottom = make([]WordCount, n)
+	for i := range s.Bottom {
+		s.Bottom[i] = counts[len(counts)-1-i]
+	}
+	return s
+}
+
+// distribution buckets the given counts, which must be sorted most first
+func distribution(counts []WordCount) []Bucket {
+	highest := counts[0].Count
+	buckets := make([]Bucket, 0)
+	for i, min := range bucketEdges {
+		if min > highest {
+			break
+		}
+		b := Bucket{Min: min, Max: -1}
+		if i+1 < len(bucketEdges) {
+			b.Max = bucketEdges[i+1] - 1
+		}
+		buckets = append(buckets, b)
+	}
+	for _, c := range counts {
+		for i := len(buckets) - 1; i >= 0; i-- {
+			if c.Count >= buckets[i].Min {
+				buckets[i].Words++
+				break
+			}
+		}
+	}
+	return buckets
+}
+
+// stages lists the stages in order, for writing
+var stages = []Stage{StageUnseen, StageSeen, StageMemorised, StageLearned}
+
+// WriteStatsText writes the given stats to w as a set of tables, one per
+// Stats.
+func WriteStatsText(w io.Writer, all []Stats) error {
+	for i, s := range all {
+		lines := make([]string, 0)
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		if s.Name != "" {
+			lines = append(lines, s.Name+":")
+		}
+		lines = append(lines, fmt.Sprintf("  words\t%d", s.Words), fmt.Sprintf("  strokes\t%d", s.Strokes))
+		for _, stage := range stages {
+			lines = append(lines, fmt.Sprintf("  %s\t%d\t%s", stage, s.Stages[stage], percent(s.Stages[stage], s.Words)))
+		}
+		lines = append(lines, "distribution (times typed: words):")
+		for _, b := range s.Distribution {
+			lines = append(lines, fmt.Sprintf("  %s\t%d", b.Label(), b.Words))
+		}
+		lines = append(lines, fmt.Sprintf("top (%d):", len(s.Top)))
+		for _, c := range s.Top {
+			lines = append(lines, fmt.Sprintf("  %s\t%d", c.Word, c.Count))
+		}
+		lines = append(lines, fmt.Sprintf("bottom (%d):", len(s.Bottom)))
+		for _, c := range s.Bottom {
+			lines = append(lines, fmt.Sprintf("  %s\t%d", c.Word, c.Count))
+		}
+		for _, line := range lines {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func percent(part, whole int) string {
+	if whole == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(part)*100/float64(whole))
+}
+
+// ReadWordList reads a list of words, one per line. Anything after a tab is
+// ignored, so a lesson file (word<TAB>stroke) can be read as a word list.
+// Blank lines are skipped.
+func ReadWordList(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	words := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if i := strings.IndexByte(line, '\t'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		words = append(words, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return words, nil
+}
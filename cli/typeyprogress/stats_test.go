@@ -0,0 +1,63 @@
+package typeyprogress
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewStats(t *testing.T) {
+	progress := map[string]int{"the": 120, "of": 30, "and": 29, "to": 1, "a": 3}
+
+	s := NewStats(progress, nil, DefaultThresholds, 2)
+	if s.Words != 5 || s.Strokes != 183 {
+		t.Errorf("expected 5 words typed 183 times, got %d typed %d times", s.Words, s.Strokes)
+	}
+	expectedStages := map[Stage]int{StageUnseen: 0, StageSeen: 3, StageMemorised: 1, StageLearned: 1}
+	if !reflect.DeepEqual(s.Stages, expectedStages) {
+		t.Errorf("expected stages %v, got %v", expectedStages, s.Stages)
+	}
+	expectedTop := []WordCount{{"the", 120}, {"of", 30}}
+	if !reflect.DeepEqual(s.Top, expectedTop) {
+		t.Errorf("expected top %v, got %v", expectedTop, s.Top)
+	}
+	expectedBottom := []WordCount{{"to", 1}, {"a", 3}}
+	if !reflect.DeepEqual(s.Bottom, expectedBottom) {
+		t.Errorf("expected bottom %v, got %v", expectedBottom, s.Bottom)
+	}
+	labels := make([]string, 0)
+	words := make([]int, 0)
+	for _, b := range s.Distribution {
+		labels = append(labels, b.Label())
+		words = append(words, b.Words)
+	}
+	expectedLabels := []string{"0", "1", "2-4", "5-9", "10-19", "20-29", "30-49", "50-99", "100-199"}
+	expectedWords := []int{0, 1, 1, 0, 0, 1, 1, 0, 1}
+	if !reflect.DeepEqual(labels, expectedLabels) || !reflect.DeepEqual(words, expectedWords) {
+		t.Errorf("expected distribution %v %v, got %v %v", expectedLabels, expectedWords, labels, words)
+	}
+
+	s = NewStats(progress, []string{"the", "zebra", "the"}, DefaultThresholds, 5)
+	if s.Words != 2 || s.Stages[StageUnseen] != 1 || s.Stages[StageLearned] != 1 {
+		t.Errorf("expected a word list to count its own words, got %+v", s)
+	}
+	if len(s.Bottom) != 2 || s.Bottom[0] != (WordCount{"zebra", 0}) {
+		t.Errorf("expected an untyped word at the bottom, got %v", s.Bottom)
+	}
+
+	s = NewStats(progress, nil, DefaultThresholds, -1)
+	if len(s.Top) != 0 || len(s.Bottom) != 0 {
+		t.Errorf("expected a negative n to list no words, got %v and %v", s.Top, s.Bottom)
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	if err := DefaultThresholds.Validate(); err != nil {
+		t.Errorf("expected the default thresholds to be valid, got %v", err)
+	}
+	if err := (Thresholds{Seen: 1, Memorised: 30, Learned: 30}).Validate(); err == nil {
+		t.Errorf("expected thresholds that don't increase to be invalid")
+	}
+	if label := (Bucket{Min: 1000, Max: -1}).Label(); label != "1000+" {
+		t.Errorf("expected an open-ended bucket to be labelled 1000+, got %s", label)
+	}
+}
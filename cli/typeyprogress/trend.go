@@ -0,0 +1,183 @@
+package typeyprogress
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// dateLayout is the format of the dates in a trend
+const dateLayout = "2006-01-02"
+
+// Day is the progress made on one day: the change since the last day before
+// it that has a snapshot
+type Day struct {
+	Date string `json:"date"`
+	// Words is the number of words typed for the first time
+	Words int `json:"words"`
+	// Strokes is the number of times words were typed correctly
+	Strokes int `json:"strokes"`
+}
+
+// WordGrowth is the change in a word's count over a trend
+type WordGrowth struct {
+	Word   string  `json:"word"`
+	From   int     `json:"from"`
+	To     int     `json:"to"`
+	PerDay float64 `json:"perDay"`
+}
+
+// Trend is the progress made between two snapshots
+type Trend struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	Days float64   `json:"days"`
+	// WordsGained is the number of words typed for the first time
+	WordsGained   int     `json:"wordsGained"`
+	WordsPerDay   float64 `json:"wordsPerDay"`
+	StrokesGained int     `json:"strokesGained"`
+	StrokesPerDay float64 `json:"strokesPerDay"`
+	// Daily is the progress made each day, leaving out the first
+	Daily []Day `json:"daily"`
+	// Fastest are the words whose counts grew the most, most first
+	Fastest []WordGrowth `json:"fastest"`
+	// Stuck are words that were seen but not memorised by the first snapshot,
+	// and haven't been typed since, least typed first
+	Stuck []WordCount `json:"stuck"`
+}
+
+// NewTrend returns the progress made over the given snapshots, which must be
+// oldest first. Days are in UTC, and each is summed up by its last snapshot.
+// Rates are per day, counting less than a day as a whole one. Fastest and
+// Stuck hold up to n words each (none if n is negative).
+func NewTrend(snapshots []Snapshot, t Thresholds, n int) (Trend, error) {
+	if len(snapshots) < 2 {
+		return Trend{}, fmt.Errorf("a trend needs at least 2 snapshots, but there are %d", len(snapshots))
+	}
+	if n < 0 {
+		n = 0
+	}
+	first, last := snapshots[0], snapshots[len(snapshots)-1]
+	trend := Trend{
+		From:          first.Time,
+		To:            last.Time,
+		Days:          last.Time.Sub(first.Time).Hours() / 24,
+		WordsGained:   newWords(first.Progress, last.Progress),
+		StrokesGained: last.Strokes - first.Strokes,
+		Daily:         make([]Day, 0),
+		Fastest:       make([]WordGrowth, 0),
+		Stuck:         make([]WordCount, 0),
+	}
+	// rates over less than a day would be misleadingly large
+	span := math.Max(trend.Days, 1)
+	trend.WordsPerDay = float64(trend.WordsGained) / span
+	trend.StrokesPerDay = float64(trend.StrokesGained) / span
+
+	// the last snapshot of each day
+	days := make([]Snapshot, 0)
+	for _, s := range snapshots {
+		if len(days) > 0 && days[len(days)-1].Time.Format(dateLayout) == s.Time.Format(dateLayout) {
+			days[len(days)-1] = s
+			continue
+		}
+		days = append(days, s)
+	}
+	for i := 1; i < len(days); i++ {
+		trend.Daily = append(trend.Daily, Day{
+			Date:    days[i].Time.Format(dateLayout),
+			Words:   newWords(days[i-1].Progress, days[i].Progress),
+			Strokes: days[i].Strokes - days[i-1].Strokes,
+		})
+	}
+
+	for word, to := range last.Progress {
+		from := first.Progress[word]
+		if to <= from {
+			continue
+		}
+		trend.Fastest = append(trend.Fastest, WordGrowth{word, from, to, float64(to-from) / span})
+	}
+	sort.Slice(trend.Fastest, func(i, j int) bool {
+		a, b := trend.Fastest[i], trend.Fastest[j]
+		if a.To-a.From != b.To-b.From {
+			return a.To-a.From > b.To-b.From
+		}
+		return a.Word < b.Word
+	})
+	if len(trend.Fastest) > n {
+		trend.Fastest = trend.Fastest[:n]
+	}
+
+	for word, from := range first.Progress {
+		if t.Stage(from) == StageSeen && last.Progress[word] == from {
+			trend.Stuck = append(trend.Stuck, WordCount{word, from})
+		}
+	}
+	sort.Slice(trend.Stuck, func(i, j int) bool {
+		a, b := trend.Stuck[i], trend.Stuck[j]
+		if a.Count != b.Count {
+			return a.Count < b.Count
+		}
+		return a.Word < b.Word
+	})
+	if len(trend.Stuck) > n {
+		trend.Stuck = trend.Stuck[:n]
+	}
+	return trend, nil
+}
+
+// newWords returns the number of words in b that are not in a
+func newWords(a, b map[string]int) int {
+	count := 0
+	for word := range b {
+		if _, ok := a[word]; !ok {
+			count++
+		}
+	}
+	return count
+}
+
+// WriteTrendText writes the given trend to w as a set of tables
+func WriteTrendText(w io.Writer, trend Trend) error {
+	lines := []string{
+		fmt.Sprintf("%s to %s (%.1f days):", trend.From.Format(time.RFC3339), trend.To.Format(time.RFC3339), trend.Days),
+		fmt.Sprintf("  words\t%+d\t%.1f/day", trend.WordsGained, trend.WordsPerDay),
+		fmt.Sprintf("  strokes\t%+d\t%.1f/day", trend.StrokesGained, trend.StrokesPerDay),
+		fmt.Sprintf("daily (%d):", len(trend.Daily)),
+	}
+	for _, d := range trend.Daily {
+		lines = append(lines, fmt.Sprintf("  %s\t%+d words\t%+d strokes", d.Date, d.Words, d.Strokes))
+	}
+	lines = append(lines, fmt.Sprintf("fastest (%d):", len(trend.Fastest)))
+	for _, g := range trend.Fastest {
+		lines = append(lines, fmt.Sprintf("  %s\t%d -> %d\t%.1f/day", g.Word, g.From, g.To, g.PerDay))
+	}
+	lines = append(lines, fmt.Sprintf("stuck (%d):", len(trend.Stuck)))
+	for _, c := range trend.Stuck {
+		lines = append(lines, fmt.Sprintf("  %s\t%d", c.Word, c.Count))
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteSnapshotsText writes the given snapshots to w, one per line, with the
+// change since the one before
+func WriteSnapshotsText(w io.Writer, snapshots []Snapshot) error {
+	for i, s := range snapshots {
+		line := fmt.Sprintf("%s\t%d words\t%d strokes", s.Time.Format(time.RFC3339), s.Words, s.Strokes)
+		if i > 0 {
+			previous := snapshots[i-1]
+			line += fmt.Sprintf("\t%+d words\t%+d strokes", newWords(previous.Progress, s.Progress), s.Strokes-previous.Strokes)
+		}
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", line, s.Filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
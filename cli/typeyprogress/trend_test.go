@@ -0,0 +1,83 @@
+package typeyprogress
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "steno")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewStore(filepath.Join(dir, "history"))
+
+	snapshots, err := store.Snapshots()
+	if err != nil || len(snapshots) != 0 {
+		t.Fatalf("expected an empty store, got %v (%v)", snapshots, err)
+	}
+
+	day := time.Date(2020, 10, 18, 9, 30, 0, 0, time.UTC)
+	progress := []map[string]int{
+		{"the": 10, "of": 5, "cat": 2},
+		{"the": 20, "of": 5, "cat": 2, "dog": 1},
+		{"the": 40, "of": 6, "cat": 2, "dog": 3, "and": 4},
+	}
+	times := []time.Time{day, day.Add(time.Hour), day.Add(48 * time.Hour)}
+	for i := range progress {
+		s, saved, err := store.Save(progress[i], times[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !saved || s.Words != len(progress[i]) {
+			t.Errorf("expected snapshot %d to be saved with %d words, got %+v (%v)", i, len(progress[i]), s, saved)
+		}
+	}
+	if _, saved, err := store.Save(progress[2], day.Add(72*time.Hour)); err != nil || saved {
+		t.Errorf("expected an unchanged snapshot not to be saved (%v)", err)
+	}
+	if _, _, err := store.Save(progress[0], day); err == nil {
+		t.Errorf("expected a snapshot older than the latest to be an error")
+	}
+
+	snapshots, err = store.Snapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 3 || !snapshots[0].Time.Equal(day) || snapshots[2].Strokes != 55 {
+		t.Fatalf("expected 3 snapshots, oldest first, got %+v", snapshots)
+	}
+
+	trend, err := NewTrend(snapshots, DefaultThresholds, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trend.Days != 2 || trend.WordsGained != 2 || trend.StrokesGained != 38 || trend.StrokesPerDay != 19 {
+		t.Errorf("expected 2 words and 38 strokes gained over 2 days, got %+v", trend)
+	}
+	expectedDaily := []Day{{"2020-10-20", 1, 27}}
+	if !reflect.DeepEqual(trend.Daily, expectedDaily) {
+		t.Errorf("expected daily %v, got %v", expectedDaily, trend.Daily)
+	}
+	expectedFastest := []WordGrowth{{"the", 10, 40, 15}, {"and", 0, 4, 2}}
+	if !reflect.DeepEqual(trend.Fastest, expectedFastest) {
+		t.Errorf("expected fastest %v, got %v", expectedFastest, trend.Fastest)
+	}
+	expectedStuck := []WordCount{{"cat", 2}}
+	if !reflect.DeepEqual(trend.Stuck, expectedStuck) {
+		t.Errorf("expected stuck %v, got %v", expectedStuck, trend.Stuck)
+	}
+
+	if trend, err = NewTrend(snapshots, DefaultThresholds, -1); err != nil || len(trend.Fastest) != 0 || len(trend.Stuck) != 0 {
+		t.Errorf("expected a negative n to list no words, got %v and %v (%v)", trend.Fastest, trend.Stuck, err)
+	}
+
+	if _, err := NewTrend(snapshots[:1], DefaultThresholds, 2); err == nil {
+		t.Errorf("expected a trend of one snapshot to be an error")
+	}
+}
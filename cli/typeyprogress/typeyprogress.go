@@ -3,10 +3,8 @@ package typeyprogress
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
+	"io"
 	"io/ioutil"
-	"sort"
-	"strings"
 )
 
 func ReadFile(filename string) (map[string]int, error) {
@@ -21,61 +19,25 @@ func ReadFile(filename string) (map[string]int, error) {
 	return inJSON, nil
 }
 
-func Clean(a map[string]int) map[string]int {
-	chordCount := 0
-	// trim space from keys
-	trimmed := make(map[string]int)
-	for k, v := range a {
-		trimmedKey := strings.TrimSpace(k)
-		existing, ok := trimmed[trimmedKey]
-		chordCount += v
-		if ok {
-			trimmed[trimmedKey] = existing + v
-		} else {
-			trimmed[trimmedKey] = v
-		}
-	}
-	fmt.Printf("%d words chorded correctly %d times\n", len(a), chordCount)
-	return trimmed
-}
-
+// Merge adds together the counts of two progress maps (see MergeWith)
 func Merge(a, b map[string]int) (map[string]int, error) {
-	wordCount := 0
-	chordCount := 0
-	c := make(map[string]int, len(a))
-	for k, v := range a {
-		c[k] = v
-		wordCount++
-		chordCount += v
-	}
-	for k, v := range b {
-		if prior, ok := c[k]; ok {
-			c[k] = prior + v
-		} else {
-			c[k] = v
-			wordCount++
-		}
-		chordCount += v
-	}
-	fmt.Printf("%d words chorded correctly %d times\n", wordCount, chordCount)
-	return c, nil
+	return MergeWith(a, b, MergeSum), nil
 }
 
 func WriteFile(j map[string]int, filename string) error {
-	var keys []string
-	for k := range j {
-		keys = append(keys, k)
+	buf := new(bytes.Buffer)
+	if err := Write(buf, j); err != nil {
+		return err
 	}
-	sort.Strings(keys)
+	return ioutil.WriteFile(filename, buf.Bytes(), 0644)
+}
 
+// Write writes the given progress to w as JSON, sorted by word, and without
+// escaping HTML characters
+func Write(w io.Writer, j map[string]int) error {
 	// json loves to escape some html characters
-	buf := new(bytes.Buffer)
-	enc := json.NewEncoder(buf)
+	enc := json.NewEncoder(w)
 	enc.SetEscapeHTML(false)
 	enc.SetIndent("", "  ")
-	if err := enc.Encode(&j); err != nil {
-		return err
-	}
-
-	return ioutil.WriteFile(filename, buf.Bytes(), 0644)
+	return enc.Encode(&j)
 }
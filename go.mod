@@ -5,4 +5,5 @@ go 1.14
 require (
 	github.com/apex/log v1.9.0
 	github.com/spf13/cobra v1.1.1
+	golang.org/x/text v0.3.8
 )